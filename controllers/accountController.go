@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// softDeleteUser keeps the user document so references to it stay valid, but
+// replaces every piece of personal data, removes all credentials and ends
+// every session and API key. The account can no longer log in. The IP and
+// device of each session and the failed login record kept under the email
+// are personal data too and are scrubbed as well.
+func softDeleteUser(ctx context.Context, userId string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.M{
+		"$set": bson.M{
+			"first_name":     "Deleted",
+			"last_name":      "User",
+			"email":          "deleted-" + userId + "@invalid",
+			"email_verified": false,
+			"totp_enabled":   false,
+			"deleted_at":     now,
+			"updated_at":     now,
+		},
+		"$unset": bson.M{
+			"phone":                         "",
+			"password":                      "",
+			"pending_email":                 "",
+			"email_verification_hash":       "",
+			"email_verification_expires_at": "",
+			"password_reset_hash":           "",
+			"password_reset_expires_at":     "",
+			"totp_secret":                   "",
+			"recovery_codes":                "",
+			"oidc_issuer":                   "",
+			"oidc_subject":                  "",
+			"mentor_id":                     "",
+		},
+	}
+
+	var previous models.User
+	err := userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId, "deleted_at": nil}, update).Decode(&previous)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeUserAPIKeys(ctx, userId); err != nil {
+		return err
+	}
+	if err := revokeCalendarFeeds(ctx, userId); err != nil {
+		return err
+	}
+	if _, err := userCollection.UpdateMany(ctx, bson.M{"mentor_id": userId}, bson.M{"$unset": bson.M{"mentor_id": ""}}); err != nil {
+		return err
+	}
+	if previous.Email != nil {
+		if err := helpers.ResetLoginFailures(*previous.Email); err != nil {
+			return err
+		}
+	}
+	if err := helpers.RevokeUserSessions(userId); err != nil {
+		return err
+	}
+	_, err = sessionCollection.UpdateMany(ctx, bson.M{"user_id": userId}, bson.M{"$set": bson.M{"ip": "", "user_agent": ""}})
+	return err
+}
+
+func DeleteMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Password *string `json:"password"`
+		}
+		// The body is optional for accounts without a password.
+		c.ShouldBindJSON(&body)
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if user.Password != nil {
+			if body.Password == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "password is required to delete the account"})
+				return
+			}
+			if ok, msg := VerifyPassword(*body.Password, *user.Password); !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+				return
+			}
+		}
+
+		if err := softDeleteUser(ctx, user.User_id); err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "error occured while deleting account"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+	}
+}
+
+func DeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if status, err := checkTargetRank(ctx, c, c.Param("user_id")); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := softDeleteUser(ctx, c.Param("user_id")); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+	}
+}
+
+// findAll decodes every document matching filter into a slice of T. It fails
+// rather than return a partial list when a document cannot be decoded or
+// the cursor breaks off.
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter interface{}) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []T{}
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// exportBundle gathers everything stored about a user, one entry per file in
+// the export. Each entry uses the response types, which leave out secrets
+// such as password and token hashes.
+func exportBundle(ctx context.Context, userId string) (map[string]interface{}, error) {
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	sessions, err := findAll[models.Session](ctx, sessionCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := findAll[models.Api_Key](ctx, apiKeyCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	roleChanges, err := findAll[models.Role_Change](ctx, roleChangeCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := findAll[models.Enrollment](ctx, enrollmentCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	completions, err := findAll[models.Completion](ctx, completionCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	sessionResponses := []models.Session_Response{}
+	for _, session := range sessions {
+		sessionResponses = append(sessionResponses, session.Response())
+	}
+	apiKeyResponses := []models.Api_Key_Response{}
+	for _, apiKey := range apiKeys {
+		apiKeyResponses = append(apiKeyResponses, apiKey.Response())
+	}
+	roleChangeResponses := []models.Role_Change_Response{}
+	for _, roleChange := range roleChanges {
+		roleChangeResponses = append(roleChangeResponses, roleChange.Response())
+	}
+	enrollmentResponses := []models.Enrollment_Response{}
+	for _, enrollment := range enrollments {
+		enrollmentResponses = append(enrollmentResponses, enrollment.Response())
+	}
+	completionResponses := []models.Completion_Response{}
+	for _, completion := range completions {
+		completionResponses = append(completionResponses, completion.Response())
+	}
+
+	return map[string]interface{}{
+		"profile":      user.Public(),
+		"sessions":     sessionResponses,
+		"api_keys":     apiKeyResponses,
+		"role_changes": roleChangeResponses,
+		"enrollments":  enrollmentResponses,
+		"completions":  completionResponses,
+	}, nil
+}
+
+func ExportMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		bundle, err := exportBundle(ctx, c.GetString("uid"))
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "error occured while exporting data"})
+			return
+		}
+
+		if c.Query("format") == "json" {
+			c.JSON(http.StatusOK, bundle)
+			return
+		}
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="gate-export.zip"`)
+		c.Status(http.StatusOK)
+
+		archive := zip.NewWriter(c.Writer)
+		for name, data := range bundle {
+			file, err := archive.Create(name + ".json")
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(data); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
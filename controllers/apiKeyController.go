@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var apiKeyCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "api_key")
+
+func CreateAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// API keys cannot mint further API keys.
+		if _, ok := c.Get("api_key_id"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "api keys cannot be created with an api key"})
+			return
+		}
+
+		var apiKey models.Api_Key
+
+		if err := c.BindJSON(&apiKey); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(apiKey)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		// A key may only carry permissions its owner has.
+		for _, scope := range apiKey.Scopes {
+			if !helpers.IsKnownPermission(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope " + scope})
+				return
+			}
+			if !helpers.HasPermission(c.GetString("user_type"), scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you do not have the permission " + scope})
+				return
+			}
+		}
+
+		if apiKey.Expires_at != nil && apiKey.Expires_at.Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+
+		key, hash, prefix, err := helpers.NewAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating api key"})
+			return
+		}
+
+		apiKey.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		apiKey.ID = primitive.NewObjectID()
+		apiKey.Api_key_id = apiKey.ID.Hex()
+		apiKey.User_id = c.GetString("uid")
+		apiKey.Key_hash = hash
+		apiKey.Prefix = prefix
+		apiKey.Last_used_at = nil
+		apiKey.Revoked_at = nil
+
+		_, err = apiKeyCollection.InsertOne(ctx, apiKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Api key was not created"})
+			return
+		}
+
+		// The key itself is only returned once; afterwards only its prefix
+		// is shown.
+		c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": apiKey.Response()})
+	}
+}
+
+func GetAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		apiKeys := []models.Api_Key_Response{}
+
+		findOptions := options.Find().SetSort(bson.M{"created_at": -1})
+		cursor, err := apiKeyCollection.Find(ctx, bson.M{"user_id": c.GetString("uid")}, findOptions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving api keys"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var apiKey models.Api_Key
+			cursor.Decode(&apiKey)
+			apiKeys = append(apiKeys, apiKey.Response())
+		}
+
+		c.JSON(http.StatusOK, apiKeys)
+	}
+}
+
+func RevokeAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		revokedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		filter := bson.M{"api_key_id": c.Param("api_key_id"), "user_id": c.GetString("uid")}
+
+		result, err := apiKeyCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking api key"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+	}
+}
+
+// revokeUserAPIKeys revokes every API key of a user, for when their password
+// changes or the account is closed.
+func revokeUserAPIKeys(ctx context.Context, userId string) error {
+	revokedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := apiKeyCollection.UpdateMany(ctx, bson.M{"user_id": userId, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+	return err
+}
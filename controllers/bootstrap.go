@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const bootstrapUserType = "SUPER_ADMIN"
+
+// BootstrapAdmin creates the first SUPER_ADMIN from BOOTSTRAP_ADMIN_EMAIL and
+// BOOTSTRAP_ADMIN_PASSWORD. It does nothing once any ADMIN or SUPER_ADMIN
+// exists, so the variables can be removed after the first start. Anyone can
+// sign up with the configured email before the operator does, so an
+// existing account is only promoted once its email has been verified.
+func BootstrapAdmin() error {
+	email := helpers.NormalizeEmail(os.Getenv("BOOTSTRAP_ADMIN_EMAIL"))
+	if email == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	count, err := userCollection.CountDocuments(ctx, bson.M{"user_type": bson.M{"$in": []string{"ADMIN", bootstrapUserType}}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	userType := bootstrapUserType
+
+	var existing models.User
+	err = userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&existing)
+	if err == nil {
+		if !existing.Email_verified || existing.Deleted_at != nil || existing.Deactivated_at != nil {
+			log.Println("not promoting", email, "to", userType+": the account's email is not verified or the account is inactive")
+			return nil
+		}
+		update := bson.M{"$set": bson.M{
+			"user_type":       userType,
+			"role_updated_by": "bootstrap",
+			"role_updated_at": now,
+			"updated_at":      now,
+		}}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"user_id": existing.User_id}, update); err != nil {
+			return err
+		}
+		log.Println("promoted", email, "to", userType)
+		return nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	plain := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if plain == "" {
+		return errors.New("BOOTSTRAP_ADMIN_PASSWORD is required to create the first admin")
+	}
+
+	firstName, lastName, phone := "Gate", "Admin", os.Getenv("BOOTSTRAP_ADMIN_PHONE")
+	password := HashPassword(plain)
+	user := models.User{
+		ID:              primitive.NewObjectID(),
+		First_Name:      &firstName,
+		Last_Name:       &lastName,
+		Email:           &email,
+		Password:        &password,
+		Phone:           &phone,
+		User_type:       &userType,
+		Email_verified:  true,
+		Role_updated_by: "bootstrap",
+		Role_updated_at: &now,
+		Created_at:      now,
+		Updated_at:      now,
+	}
+	user.User_id = user.ID.Hex()
+
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		return err
+	}
+	log.Println("created", userType, email)
+	return nil
+}
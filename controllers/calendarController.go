@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetMyEnrollments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		enrollments, err := findAll[models.Enrollment](ctx, enrollmentCollection, bson.M{"user_id": c.GetString("uid")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving enrollments"})
+			return
+		}
+
+		responses := []models.Enrollment_Response{}
+		for _, enrollment := range enrollments {
+			responses = append(responses, enrollment.Response())
+		}
+		c.JSON(http.StatusOK, responses)
+	}
+}
+
+// CreateCalendarFeed gives an enrollment a new secret feed URL. Only the
+// token's hash is stored, so the URL is shown once; asking again replaces it
+// and the old URL stops working.
+func CreateCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		token, hash, err := helpers.NewOneTimeToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating calendar feed"})
+			return
+		}
+
+		filter := bson.M{"enrollment_id": c.Param("enrollment_id"), "user_id": c.GetString("uid")}
+		update := bson.M{"$set": bson.M{"calendar_token_hash": hash}}
+		result, err := enrollmentCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating calendar feed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "enrollment not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"calendar_url": helpers.AppURL("calendar/" + token + ".ics")})
+	}
+}
+
+func DeleteCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"enrollment_id": c.Param("enrollment_id"), "user_id": c.GetString("uid")}
+		result, err := enrollmentCollection.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"calendar_token_hash": ""}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting calendar feed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "enrollment not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "calendar feed deleted"})
+	}
+}
+
+// GetCalendarFeed serves the .ics feed of an enrollment. It is public and
+// authorised by the secret token in the URL alone, since calendar apps
+// cannot send credentials. The feed is built on every request, so calendar
+// apps pick up plan changes and completed materials when they refresh.
+func GetCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		var enrollment models.Enrollment
+		err := enrollmentCollection.FindOne(ctx, bson.M{"calendar_token_hash": helpers.HashOneTimeToken(token)}).Decode(&enrollment)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "calendar not found"})
+			return
+		}
+
+		active := bson.M{"user_id": enrollment.User_id, "deleted_at": nil, "deactivated_at": nil}
+		count, err := userCollection.CountDocuments(ctx, active)
+		if err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+			return
+		}
+
+		plan, err := findExpandedPlan(ctx, enrollment.Plan_id)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study plan not found"})
+			return
+		}
+
+		completions := map[string]bool{}
+		projection := options.Find().SetProjection(bson.M{"material_id": 1})
+		cursor, err := completionCollection.Find(ctx, bson.M{"user_id": enrollment.User_id}, projection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while building calendar"})
+			return
+		}
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var completion models.Completion
+			cursor.Decode(&completion)
+			completions[completion.Material_id] = true
+		}
+
+		name := "Gate study plan"
+		if plan.Plan_Name != nil {
+			name = "Gate: " + *plan.Plan_Name
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Content-Disposition", `inline; filename="gate-plan.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(models.ICalendar(name, enrollment.CalendarEvents(plan, completions))))
+	}
+}
+
+// revokeCalendarFeeds stops every calendar feed of a user.
+func revokeCalendarFeeds(ctx context.Context, userId string) error {
+	_, err := enrollmentCollection.UpdateMany(ctx, bson.M{"user_id": userId}, bson.M{"$unset": bson.M{"calendar_token_hash": ""}})
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	return err
+}
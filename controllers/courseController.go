@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"Gate/database"
-	"Gate/helpers"
 	"Gate/models"
 	"context"
 	"log"
@@ -43,12 +42,8 @@ func (m *paginate) getPaginatedOpts() *options.FindOptions {
 
 func AddStudyMaterial() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var material models.Study_Material
 
 		if err := c.BindJSON(&material); err != nil {
@@ -62,40 +57,28 @@ func AddStudyMaterial() gin.HandlerFunc {
 			return
 		}
 
-		count, err := materialCollection.CountDocuments(ctx, bson.M{"material_title": material.Material_Title})
-		defer cancel()
-
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for material title"})
-		}
-
-		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this material title already exists"})
-		}
-
 		material.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		material.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		material.ID = primitive.NewObjectID()
 		material.Material_Id = material.ID.Hex()
 
-		num, err := materialCollection.InsertOne(ctx, material)
+		_, err := materialCollection.InsertOne(ctx, material)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this material title already exists"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Material was not created"})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, num)
+		c.JSON(http.StatusOK, material.Response())
 	}
 }
 
 func AddCourse() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var course models.Course
 
 		if err := c.BindJSON(&course); err != nil {
@@ -109,40 +92,39 @@ func AddCourse() gin.HandlerFunc {
 			return
 		}
 
-		count, err := courseCollection.CountDocuments(ctx, bson.M{"course_name": course.Course_Name})
-		defer cancel()
-
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for course name"})
+		if !checkReferences(ctx, c, materialCollection, "material_id", course.Material_ids) {
+			return
 		}
 
-		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this course name already exists"})
+		total, err := courseDuration(ctx, course.Material_ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Course was not created"})
+			return
 		}
+		course.Total_Duration = total
 
 		course.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		course.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		course.ID = primitive.NewObjectID()
 		course.Course_Id = course.ID.Hex()
 
-		num, err := courseCollection.InsertOne(ctx, course)
+		_, err = courseCollection.InsertOne(ctx, course)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this course name already exists"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Course was not created"})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, num)
+		c.JSON(http.StatusOK, course.Response())
 	}
 }
 
 func AddStudyPlan() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var plan models.Study_Plan
 
 		if err := c.BindJSON(&plan); err != nil {
@@ -156,42 +138,41 @@ func AddStudyPlan() gin.HandlerFunc {
 			return
 		}
 
-		count, err := planCollection.CountDocuments(ctx, bson.M{"plan_name": plan.Plan_Name})
-		defer cancel()
-
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for plan name"})
+		if !checkReferences(ctx, c, courseCollection, "course_id", plan.Course_ids) {
+			return
 		}
 
-		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this plan name already exists"})
+		total, err := planDuration(ctx, plan.Course_ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Study Plan was not created"})
+			return
 		}
+		plan.Total_Duration = total
 
 		plan.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		plan.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		plan.ID = primitive.NewObjectID()
 		plan.Plan_id = plan.ID.Hex()
 
-		num, err := planCollection.InsertOne(ctx, plan)
+		_, err = planCollection.InsertOne(ctx, plan)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this plan name already exists"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Study Plan was not created"})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, num)
+		c.JSON(http.StatusOK, plan.Response())
 	}
 }
 
 func GetStudyMaterials() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		var ctx, _ = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var study_material []models.Study_Material
+		study_material := []models.Study_Material_Response{}
 
 		page, err := strconv.Atoi(c.Query("page"))
 		if err != nil || page < 1 {
@@ -240,30 +221,26 @@ func GetStudyMaterials() gin.HandlerFunc {
 		cursor, err := materialCollection.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving study materials"})
+			return
 		}
 		defer cursor.Close(ctx)
 
 		for cursor.Next(ctx) {
 			var material models.Study_Material
 			cursor.Decode(&material)
-			study_material = append(study_material, material)
+			study_material = append(study_material, material.Response())
 		}
 
-		c.JSON(http.StatusOK, study_material[0])
-
+		c.JSON(http.StatusOK, study_material)
 	}
 }
 
 func GetCourses() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		var ctx, _ = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var courses []models.Course
+		courses := []models.Course_Response{}
 
 		page, err := strconv.Atoi(c.Query("page"))
 		if err != nil || page < 1 {
@@ -304,30 +281,26 @@ func GetCourses() gin.HandlerFunc {
 		cursor, err := courseCollection.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving courses"})
+			return
 		}
 		defer cursor.Close(ctx)
 
 		for cursor.Next(ctx) {
 			var course models.Course
 			cursor.Decode(&course)
-			courses = append(courses, course)
+			courses = append(courses, course.Response())
 		}
 
-		c.JSON(http.StatusOK, courses[0])
-
+		c.JSON(http.StatusOK, courses)
 	}
 }
 
 func GetStudyPlans() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		var ctx, _ = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var plans []models.Study_Plan
+		plans := []models.Study_Plan_Response{}
 
 		page, err := strconv.Atoi(c.Query("page"))
 		if err != nil || page < 1 {
@@ -368,17 +341,17 @@ func GetStudyPlans() gin.HandlerFunc {
 		cursor, err := planCollection.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving study plans"})
+			return
 		}
 		defer cursor.Close(ctx)
 
 		for cursor.Next(ctx) {
 			var plan models.Study_Plan
 			cursor.Decode(&plan)
-			plans = append(plans, plan)
+			plans = append(plans, plan.Response())
 		}
 
-		c.JSON(http.StatusOK, plans[0])
-
+		c.JSON(http.StatusOK, plans)
 	}
 }
 
@@ -392,10 +365,10 @@ func GetStudyMaterial() gin.HandlerFunc {
 		err := materialCollection.FindOne(ctx, bson.M{"material_id": id}).Decode(&material)
 		defer cancel()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, material)
+		c.JSON(http.StatusOK, material.Response())
 	}
 }
 
@@ -404,15 +377,23 @@ func GetCourse() gin.HandlerFunc {
 		id := c.Param("course")
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var course models.Course
 
-		err := courseCollection.FindOne(ctx, bson.M{"course_id": id}).Decode(&course)
-		defer cancel()
+		expand := c.Query("expand")
+		stages := courseExpandStages(expand)
+		if expand != "" && stages == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expand must be materials"})
+			return
+		}
+
+		err := findOneExpanded(ctx, courseCollection, bson.M{"course_id": id}, stages, &course)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, course)
+		course.OrderMaterials()
+		c.JSON(http.StatusOK, course.Response())
 	}
 }
 
@@ -421,14 +402,344 @@ func GetStudyPlan() gin.HandlerFunc {
 		id := c.Param("study_plan")
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var plan models.Study_Plan
 
-		err := planCollection.FindOne(ctx, bson.M{"plan_id": id}).Decode(&plan)
+		expand := c.Query("expand")
+		stages := planExpandStages(expand)
+		if expand != "" && stages == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expand must be courses or courses.materials"})
+			return
+		}
+
+		err := findOneExpanded(ctx, planCollection, bson.M{"plan_id": id}, stages, &plan)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		plan.OrderCourses()
+		c.JSON(http.StatusOK, plan.Response())
+	}
+}
+
+// UpdateStudyMaterial serves PUT, which replaces the material, and PATCH,
+// which only changes the fields in the body.
+func UpdateStudyMaterial() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var existing models.Study_Material
+		err := materialCollection.FindOne(ctx, bson.M{"material_id": c.Param("study_material")}).Decode(&existing)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study material not found"})
+			return
+		}
+
+		var material models.Study_Material
+		if c.Request.Method == http.MethodPatch {
+			material = existing
+		}
+		if err := c.BindJSON(&material); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(material)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		material.ID = existing.ID
+		material.Material_Id = existing.Material_Id
+		material.Created_at = existing.Created_at
+		material.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		_, err = materialCollection.ReplaceOne(ctx, bson.M{"_id": existing.ID}, material)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this material title already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Material was not updated"})
+			return
+		}
+
+		// Recomputing even when the duration did not change lets a retry of
+		// the same update repair totals an earlier failure left behind.
+		if err := recomputeCourses(ctx, bson.M{"material_ids": material.Material_Id}); err != nil {
+			warnDurationsNotRecomputed(c, err)
+		}
+
+		c.JSON(http.StatusOK, material.Response())
+	}
+}
+
+// UpdateCourse serves PUT and PATCH like UpdateStudyMaterial.
+func UpdateCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
+
+		var existing models.Course
+		err := courseCollection.FindOne(ctx, bson.M{"course_id": c.Param("course")}).Decode(&existing)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(findErrorStatus(err), gin.H{"error": "course not found"})
 			return
 		}
-		c.JSON(http.StatusOK, plan)
+
+		var course models.Course
+		if c.Request.Method == http.MethodPatch {
+			course = existing
+		}
+		if err := c.BindJSON(&course); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(course)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		if !checkReferences(ctx, c, materialCollection, "material_id", course.Material_ids) {
+			return
+		}
+
+		course.Total_Duration, err = courseDuration(ctx, course.Material_ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Course was not updated"})
+			return
+		}
+
+		course.ID = existing.ID
+		course.Course_Id = existing.Course_Id
+		course.Created_at = existing.Created_at
+		course.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		_, err = courseCollection.ReplaceOne(ctx, bson.M{"_id": existing.ID}, course)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this course name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Course was not updated"})
+			return
+		}
+
+		if err := recomputePlans(ctx, bson.M{"course_ids": course.Course_Id}); err != nil {
+			warnDurationsNotRecomputed(c, err)
+		}
+
+		c.JSON(http.StatusOK, course.Response())
+	}
+}
+
+// UpdateStudyPlan serves PUT and PATCH like UpdateStudyMaterial.
+func UpdateStudyPlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var existing models.Study_Plan
+		err := planCollection.FindOne(ctx, bson.M{"plan_id": c.Param("study_plan")}).Decode(&existing)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study plan not found"})
+			return
+		}
+
+		var plan models.Study_Plan
+		if c.Request.Method == http.MethodPatch {
+			plan = existing
+		}
+		if err := c.BindJSON(&plan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(plan)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		if !checkReferences(ctx, c, courseCollection, "course_id", plan.Course_ids) {
+			return
+		}
+
+		plan.Total_Duration, err = planDuration(ctx, plan.Course_ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Study Plan was not updated"})
+			return
+		}
+
+		plan.ID = existing.ID
+		plan.Plan_id = existing.Plan_id
+		plan.Created_at = existing.Created_at
+		plan.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		_, err = planCollection.ReplaceOne(ctx, bson.M{"_id": existing.ID}, plan)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this plan name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Study Plan was not updated"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan.Response())
+	}
+}
+
+// distinctIds returns the ids of the documents matching filter.
+func distinctIds(ctx context.Context, collection *mongo.Collection, field string, filter bson.M) ([]interface{}, error) {
+	ids, err := collection.Distinct(ctx, field, filter)
+	if ids == nil {
+		ids = []interface{}{}
+	}
+	return ids, err
+}
+
+// DeleteStudyMaterial refuses with 409 while courses still list the material,
+// naming them. With ?cascade=true the material is removed from them first.
+func DeleteStudyMaterial() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param("study_material")
+		count, err := materialCollection.CountDocuments(ctx, bson.M{"material_id": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study material"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "study material not found"})
+			return
+		}
+
+		courses, err := distinctIds(ctx, courseCollection, "course_id", bson.M{"material_ids": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study material"})
+			return
+		}
+		if len(courses) > 0 && c.Query("cascade") != "true" {
+			c.JSON(http.StatusConflict, gin.H{"error": "the study material is in use, delete with ?cascade=true to remove it everywhere", "courses": courses})
+			return
+		}
+
+		if len(courses) > 0 {
+			_, err = courseCollection.UpdateMany(ctx, bson.M{"material_ids": id}, bson.M{"$pull": bson.M{"material_ids": id}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study material"})
+				return
+			}
+		}
+
+		if _, err := materialCollection.DeleteOne(ctx, bson.M{"material_id": id}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study material"})
+			return
+		}
+
+		if len(courses) > 0 {
+			if err := recomputeCourses(ctx, bson.M{"course_id": bson.M{"$in": courses}}); err != nil {
+				warnDurationsNotRecomputed(c, err)
+				c.JSON(http.StatusOK, gin.H{"message": "study material deleted", "warning": durationsNotRecomputed})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "study material deleted"})
+	}
+}
+
+// DeleteCourse follows the same policy as DeleteStudyMaterial for the plans
+// that list the course.
+func DeleteCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param("course")
+		count, err := courseCollection.CountDocuments(ctx, bson.M{"course_id": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting course"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "course not found"})
+			return
+		}
+
+		plans, err := distinctIds(ctx, planCollection, "plan_id", bson.M{"course_ids": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting course"})
+			return
+		}
+		if len(plans) > 0 && c.Query("cascade") != "true" {
+			c.JSON(http.StatusConflict, gin.H{"error": "the course is in use, delete with ?cascade=true to remove it everywhere", "study_plans": plans})
+			return
+		}
+
+		if len(plans) > 0 {
+			_, err = planCollection.UpdateMany(ctx, bson.M{"course_ids": id}, bson.M{"$pull": bson.M{"course_ids": id}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting course"})
+				return
+			}
+		}
+
+		if _, err := courseCollection.DeleteOne(ctx, bson.M{"course_id": id}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting course"})
+			return
+		}
+
+		if len(plans) > 0 {
+			if err := recomputePlans(ctx, bson.M{"plan_id": bson.M{"$in": plans}}); err != nil {
+				warnDurationsNotRecomputed(c, err)
+				c.JSON(http.StatusOK, gin.H{"message": "course deleted", "warning": durationsNotRecomputed})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "course deleted"})
+	}
+}
+
+// DeleteStudyPlan refuses with 409 while students are enrolled. With
+// ?cascade=true their enrollments are removed too; completions are kept.
+func DeleteStudyPlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param("study_plan")
+		enrolled, err := enrollmentCollection.CountDocuments(ctx, bson.M{"plan_id": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study plan"})
+			return
+		}
+		if enrolled > 0 && c.Query("cascade") != "true" {
+			c.JSON(http.StatusConflict, gin.H{"error": "students are enrolled in the study plan, delete with ?cascade=true to remove their enrollments", "enrollments": enrolled})
+			return
+		}
+
+		result, err := planCollection.DeleteOne(ctx, bson.M{"plan_id": id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting study plan"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "study plan not found"})
+			return
+		}
+
+		if enrolled > 0 {
+			if _, err := enrollmentCollection.DeleteMany(ctx, bson.M{"plan_id": id}); err != nil {
+				log.Println(err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "study plan deleted"})
 	}
 }
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"Gate/models"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Course and plan durations are derived: a course takes as long as its
+// materials and a plan as long as its courses. They are stored so lists can
+// sort on them, and recomputed whenever a material or course changes.
+
+// durationsNotRecomputed warns that a change was saved but the totals of the
+// courses or plans listing it could not be updated. Retrying the update, or
+// RecomputeDurations, repairs them.
+const durationsNotRecomputed = "the change was saved but durations could not be recomputed, retry it or recompute durations"
+
+// warnDurationsNotRecomputed logs err and adds the warning as a Warning
+// header, so the response for the saved change can still be a 200.
+func warnDurationsNotRecomputed(c *gin.Context, err error) {
+	log.Println(err)
+	c.Header("Warning", `199 - "`+durationsNotRecomputed+`"`)
+}
+
+// durationsById loads durationField of the documents whose idField is in ids.
+func durationsById(ctx context.Context, collection *mongo.Collection, idField string, durationField string, ids []string) (map[string]models.Duration, error) {
+	byId := map[string]models.Duration{}
+	if len(ids) == 0 {
+		return byId, nil
+	}
+
+	projection := options.Find().SetProjection(bson.M{idField: 1, durationField: 1})
+	cursor, err := collection.Find(ctx, bson.M{idField: bson.M{"$in": ids}}, projection)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		id, _ := cursor.Current.Lookup(idField).StringValueOK()
+		duration, _ := cursor.Current.Lookup(durationField).AsInt64OK()
+		byId[id] = models.Duration(duration)
+	}
+	return byId, cursor.Err()
+}
+
+// totalDuration adds up the durations of ids. An id listed twice counts twice.
+func totalDuration(ctx context.Context, collection *mongo.Collection, idField string, durationField string, ids []string) (models.Duration, error) {
+	byId, err := durationsById(ctx, collection, idField, durationField, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var total models.Duration
+	for _, id := range ids {
+		total += byId[id]
+	}
+	return total, nil
+}
+
+func courseDuration(ctx context.Context, materialIds []string) (models.Duration, error) {
+	return totalDuration(ctx, materialCollection, "material_id", "time_duration", materialIds)
+}
+
+func planDuration(ctx context.Context, courseIds []string) (models.Duration, error) {
+	return totalDuration(ctx, courseCollection, "course_id", "total_duration", courseIds)
+}
+
+// recomputeCourses updates the duration of the courses matching filter and
+// then of the plans that list them.
+func recomputeCourses(ctx context.Context, filter bson.M) error {
+	courses, err := findAll[models.Course](ctx, courseCollection, filter)
+	if err != nil {
+		return err
+	}
+
+	courseIds := []string{}
+	for _, course := range courses {
+		total, err := courseDuration(ctx, course.Material_ids)
+		if err != nil {
+			return err
+		}
+		_, err = courseCollection.UpdateOne(ctx, bson.M{"_id": course.ID}, bson.M{"$set": bson.M{"total_duration": total}})
+		if err != nil {
+			return err
+		}
+		courseIds = append(courseIds, course.Course_Id)
+	}
+
+	if len(courseIds) == 0 {
+		return nil
+	}
+	return recomputePlans(ctx, bson.M{"course_ids": bson.M{"$in": courseIds}})
+}
+
+// recomputePlans updates the duration of the plans matching filter.
+func recomputePlans(ctx context.Context, filter bson.M) error {
+	plans, err := findAll[models.Study_Plan](ctx, planCollection, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		total, err := planDuration(ctx, plan.Course_ids)
+		if err != nil {
+			return err
+		}
+		_, err = planCollection.UpdateOne(ctx, bson.M{"_id": plan.ID}, bson.M{"$set": bson.M{"total_duration": total}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecomputeDurations recomputes the duration of every course and plan, to
+// repair totals left stale by a failed update.
+func RecomputeDurations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := recomputeCourses(ctx, bson.M{}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while recomputing durations"})
+			return
+		}
+		// Plans without courses are not reached through their courses.
+		if err := recomputePlans(ctx, bson.M{}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while recomputing durations"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "durations recomputed"})
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const duplicateKeyCode = 11000
+
+// findErrorStatus maps an error from FindOne to a response status: 404 when
+// nothing matched, 500 for anything else.
+func findErrorStatus(err error) int {
+	if err == mongo.ErrNoDocuments {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// duplicateKeyField reports which of the given fields a duplicate key error
+// is about, going by the key pattern of the violated index that the server
+// sends with the error. It returns "" for any other error and the first
+// field when the key pattern names none of them.
+func duplicateKeyField(err error, fields ...string) string {
+	if !mongo.IsDuplicateKeyError(err) || len(fields) == 0 {
+		return ""
+	}
+	for _, key := range duplicateKeyPattern(err) {
+		for _, field := range fields {
+			if key == field {
+				return field
+			}
+		}
+	}
+	return fields[0]
+}
+
+// duplicateKeyPattern returns the fields of the index a duplicate key error
+// violated. Inserts fail with a WriteException, find-and-modify commands
+// with a CommandError; both carry the server's keyPattern.
+func duplicateKeyPattern(err error) []string {
+	var raws []bson.Raw
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == duplicateKeyCode {
+				raws = append(raws, writeError.Raw)
+			}
+		}
+	}
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) {
+		raws = append(raws, commandError.Raw)
+	}
+
+	for _, raw := range raws {
+		pattern, ok := raw.Lookup("keyPattern").DocumentOK()
+		if !ok {
+			continue
+		}
+		elements, err := pattern.Elements()
+		if err != nil {
+			continue
+		}
+		keys := []string{}
+		for _, element := range elements {
+			keys = append(keys, element.Key())
+		}
+		return keys
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var importJobCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "import_job")
+
+const (
+	inviteTTL           = 7 * 24 * time.Hour
+	maxImportRows       = 1000
+	maxImportBytes      = 1 << 20
+	importProgressEvery = 25
+)
+
+const (
+	importPending   = "pending"
+	importRunning   = "running"
+	importCompleted = "completed"
+)
+
+var importColumns = []string{"first_name", "last_name", "email", "phone"}
+
+type importRow struct {
+	number int
+	user   models.User
+}
+
+// readImportCSV reads the uploaded CSV, either as the "file" field of a
+// multipart form or as the raw request body. The first row names the
+// columns: first_name, last_name, email and phone, in any order.
+func readImportCSV(c *gin.Context) ([]importRow, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	var source io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			return nil, errors.New("the csv must be uploaded as the file field")
+		}
+		defer file.Close()
+		source = file
+	}
+
+	reader := csv.NewReader(source)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("the csv could not be read: %v", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("the csv has no rows")
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, fmt.Errorf("the csv has more than %d rows", maxImportRows)
+	}
+
+	position := map[string]int{}
+	for i, name := range records[0] {
+		position[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := position[column]; !ok {
+			return nil, errors.New("the csv is missing the " + column + " column")
+		}
+	}
+
+	rows := []importRow{}
+	for i, record := range records[1:] {
+		value := func(column string) *string {
+			v := strings.TrimSpace(record[position[column]])
+			return &v
+		}
+		email := helpers.NormalizeEmail(*value("email"))
+
+		// A blank phone is read as no phone, so validation reports it as a
+		// row error like a missing value at signup.
+		phone := value("phone")
+		if *phone == "" {
+			phone = nil
+		}
+
+		rows = append(rows, importRow{
+			number: i + 2,
+			user: models.User{
+				First_Name: value("first_name"),
+				Last_Name:  value("last_name"),
+				Email:      &email,
+				Phone:      phone,
+			},
+		})
+	}
+	return rows, nil
+}
+
+func ImportUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		rows, err := readImportCSV(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		job := models.Import_Job{
+			ID:         primitive.NewObjectID(),
+			Created_by: c.GetString("uid"),
+			Status:     importPending,
+			Total_rows: len(rows),
+			Errors:     []models.Import_Row_Error{},
+			Created_at: now,
+			Updated_at: now,
+		}
+		job.Import_job_id = job.ID.Hex()
+
+		if _, err := importJobCollection.InsertOne(ctx, job); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Import job was not created"})
+			return
+		}
+
+		go runImportJob(job, rows)
+
+		c.JSON(http.StatusAccepted, job.Response())
+	}
+}
+
+func GetImportJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var job models.Import_Job
+		err := importJobCollection.FindOne(ctx, bson.M{"import_job_id": c.Param("import_job_id")}).Decode(&job)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "import job not found"})
+			return
+		}
+		c.JSON(http.StatusOK, job.Response())
+	}
+}
+
+// runImportJob creates the accounts one row at a time and saves the job's
+// progress as it goes. A job interrupted by a restart stays "running"; its
+// rows can be uploaded again because existing emails are reported, not
+// duplicated.
+func runImportJob(job models.Import_Job, rows []importRow) {
+	ctx := context.Background()
+	seen := map[string]bool{}
+
+	job.Status = importRunning
+	saveImportJob(ctx, &job)
+
+	for _, row := range rows {
+		email := *row.user.Email
+		var err error
+		if seen[email] {
+			err = errors.New("this email appears more than once in the file")
+		} else {
+			seen[email] = true
+			err = inviteUser(ctx, row.user)
+		}
+
+		if err != nil {
+			job.Failed_count++
+			job.Errors = append(job.Errors, models.Import_Row_Error{Row: row.number, Email: *row.user.Email, Error: err.Error()})
+		} else {
+			job.Created_count++
+		}
+
+		job.Processed_rows++
+		if job.Processed_rows%importProgressEvery == 0 {
+			saveImportJob(ctx, &job)
+		}
+	}
+
+	finishedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	job.Finished_at = &finishedAt
+	job.Status = importCompleted
+	saveImportJob(ctx, &job)
+}
+
+func saveImportJob(ctx context.Context, job *models.Import_Job) {
+	job.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err := importJobCollection.ReplaceOne(ctx, bson.M{"_id": job.ID}, job); err != nil {
+		log.Println(err)
+	}
+}
+
+// inviteUser validates one imported user with the signup rules, except for
+// the password, creates the account without a password and emails an invite
+// link. The link is a password reset token with a longer lifetime, so the
+// student sets a password through users/password/reset.
+func inviteUser(ctx context.Context, user models.User) error {
+	userType := defaultUserType
+	user.User_type = &userType
+
+	if err := validate.StructExcept(user, "Password"); err != nil {
+		return err
+	}
+
+	token, hash, err := helpers.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	expiresAt := time.Now().Add(inviteTTL)
+	user.Created_at = now
+	user.Updated_at = now
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	user.Password = nil
+	user.Email_verified = false
+	user.Password_reset_hash = &hash
+	user.Password_reset_expires_at = &expiresAt
+
+	_, err = userCollection.InsertOne(ctx, user)
+	if field := duplicateKeyField(err, "email", "phone"); field != "" {
+		return errors.New("this " + field + " already exists")
+	}
+	if err != nil {
+		log.Println(err)
+		return errors.New("the user was not created")
+	}
+
+	link := helpers.ResetPasswordURL(token)
+	helpers.SendMail(helpers.Mail{
+		To:      *user.Email,
+		Subject: "You have been invited to Gate",
+		Body:    "Hi " + *user.First_Name + ",\n\nAn account has been created for you on Gate. Open the link below to choose your password. It expires in 7 days.\n\n" + link,
+	})
+	return nil
+}
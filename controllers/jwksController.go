@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := helpers.JWKS()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading signing keys"})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(helpers.JWKSMaxAge.Seconds())))
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
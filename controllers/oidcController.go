@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// oidcState is what the login step remembers for the callback. It is keyed by
+// the hash of the state parameter and deleted when the callback consumes it.
+type oidcState struct {
+	State_hash    string    `bson:"state_hash"`
+	Nonce         string    `bson:"nonce"`
+	Code_verifier string    `bson:"code_verifier"`
+	Expires_at    time.Time `bson:"expires_at"`
+}
+
+var oidcStateCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "oidc_state")
+
+func OIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !helpers.OIDCEnabled() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		state, stateHash, err := helpers.NewOneTimeToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while starting login"})
+			return
+		}
+		nonce, _, err := helpers.NewOneTimeToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while starting login"})
+			return
+		}
+		verifier, challenge, err := helpers.NewPKCE()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while starting login"})
+			return
+		}
+
+		authURL, err := helpers.OIDCAuthURL(state, nonce, challenge)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "the identity provider is unavailable"})
+			return
+		}
+
+		record := oidcState{
+			State_hash:    stateHash,
+			Nonce:         nonce,
+			Code_verifier: verifier,
+			Expires_at:    time.Now().Add(oidcStateTTL),
+		}
+		if _, err := oidcStateCollection.InsertOne(ctx, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while starting login"})
+			return
+		}
+
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+func OIDCCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !helpers.OIDCEnabled() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not configured"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if providerError := c.Query("error"); providerError != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "login was not completed: " + providerError})
+			return
+		}
+
+		state, code := c.Query("state"), c.Query("code")
+		if state == "" || code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+			return
+		}
+
+		var record oidcState
+		filter := bson.M{"state_hash": helpers.HashOneTimeToken(state), "expires_at": bson.M{"$gt": time.Now()}}
+		if err := oidcStateCollection.FindOneAndDelete(ctx, filter).Decode(&record); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the login request is invalid or expired"})
+			return
+		}
+
+		rawIDToken, err := helpers.OIDCExchangeCode(code, record.Code_verifier)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the identity provider rejected the login"})
+			return
+		}
+
+		claims, err := helpers.VerifyOIDCIDToken(rawIDToken, record.Nonce)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, status, err := linkOIDCUser(ctx, claims)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		startLogin(ctx, c, user)
+	}
+}
+
+// linkOIDCUser finds the user for an identity provider account: first by the
+// stored issuer and subject, then by verified email, linking that account.
+// A user is created when neither matches.
+//
+// Anyone can sign up with someone else's email and a password of their own,
+// so linking to a local account whose email was never verified drops every
+// credential it had. Only the identity provider can log into it afterwards.
+func linkOIDCUser(ctx context.Context, claims *helpers.OIDCClaims) (models.User, int, error) {
+	var user models.User
+	issuer := strings.TrimRight(claims.Issuer, "/")
+	claims.Email = helpers.NormalizeEmail(claims.Email)
+
+	if claims.Email == "" || !claims.Email_verified {
+		return user, http.StatusForbidden, errors.New("the identity provider did not confirm a verified email")
+	}
+
+	err := userCollection.FindOne(ctx, bson.M{"oidc_issuer": issuer, "oidc_subject": claims.Subject}).Decode(&user)
+	if err == nil {
+		return user, 0, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, err
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	err = userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&user)
+	if err == nil {
+		if user.Oidc_subject != "" {
+			return user, http.StatusConflict, errors.New("this email is already linked to another identity provider account")
+		}
+
+		update := bson.M{"$set": bson.M{
+			"oidc_issuer":    issuer,
+			"oidc_subject":   claims.Subject,
+			"email_verified": true,
+			"updated_at":     updatedAt,
+		}}
+		if !user.Email_verified {
+			update["$set"].(bson.M)["totp_enabled"] = false
+			update["$unset"] = bson.M{
+				"password":                  "",
+				"password_reset_hash":       "",
+				"password_reset_expires_at": "",
+				"totp_secret":               "",
+				"recovery_codes":            "",
+			}
+		}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update); err != nil {
+			return user, http.StatusInternalServerError, err
+		}
+
+		if !user.Email_verified {
+			if err := revokeUserAPIKeys(ctx, user.User_id); err != nil {
+				return user, http.StatusInternalServerError, err
+			}
+			if err := helpers.RevokeUserSessions(user.User_id); err != nil {
+				return user, http.StatusInternalServerError, err
+			}
+			user.Password = nil
+			user.Totp_enabled = false
+			user.Totp_secret = nil
+		}
+		user.Email_verified = true
+		return user, 0, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, err
+	}
+
+	firstName, lastName := claims.Given_name, claims.Family_name
+	if firstName == "" {
+		firstName, lastName, _ = strings.Cut(claims.Name, " ")
+	}
+	if firstName == "" {
+		firstName = strings.Split(claims.Email, "@")[0]
+	}
+
+	email, userType := claims.Email, defaultUserType
+	user = models.User{
+		ID:             primitive.NewObjectID(),
+		First_Name:     &firstName,
+		Last_Name:      &lastName,
+		Email:          &email,
+		User_type:      &userType,
+		Email_verified: true,
+		Oidc_issuer:    issuer,
+		Oidc_subject:   claims.Subject,
+		Created_at:     updatedAt,
+		Updated_at:     updatedAt,
+	}
+	user.User_id = user.ID.Hex()
+
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		return user, http.StatusInternalServerError, err
+	}
+	return user, 0, nil
+}
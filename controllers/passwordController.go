@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Email *string `json:"email" validate:"required,email"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Email != nil {
+			*body.Email = helpers.NormalizeEmail(*body.Email)
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		// The response is the same whether or not the email exists so the
+		// endpoint cannot be used to discover accounts.
+		response := gin.H{"message": "if the email is registered, a reset link has been sent"}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"email": body.Email}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		token, hash, err := helpers.NewOneTimeToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating reset token"})
+			return
+		}
+
+		expiresAt := time.Now().Add(passwordResetTTL)
+		update := bson.M{"$set": bson.M{
+			"password_reset_hash":       hash,
+			"password_reset_expires_at": expiresAt,
+		}}
+
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating reset token"})
+			return
+		}
+
+		link := helpers.ResetPasswordURL(token)
+		helpers.SendMail(helpers.Mail{
+			To:      *user.Email,
+			Subject: "Reset your Gate password",
+			Body:    "Hi " + *user.First_Name + ",\n\nUse the link below to choose a new password. It expires in 30 minutes.\n\n" + link + "\n\nIf you did not ask for a reset, you can ignore this email.",
+		})
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Token    *string `json:"token" validate:"required"`
+			Password *string `json:"password" validate:"required,min=8,max=16"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		// Matching on the hash and clearing it in the same update claims the
+		// token, so it is single-use even under concurrent requests. The
+		// password is only hashed afterwards: hashing is slow on purpose and
+		// must not be spent on made up tokens.
+		filter := bson.M{
+			"password_reset_hash":       helpers.HashOneTimeToken(*body.Token),
+			"password_reset_expires_at": bson.M{"$gt": time.Now()},
+		}
+		claim := bson.M{"$unset": bson.M{"password_reset_hash": "", "password_reset_expires_at": ""}}
+
+		var user models.User
+		err := userCollection.FindOneAndUpdate(ctx, filter, claim).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the reset token is invalid or expired"})
+			return
+		}
+
+		// The token arrived by email, so using it also confirms the email
+		// address, which is how invited users verify theirs.
+		password := HashPassword(*body.Password)
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update := bson.M{"$set": bson.M{"password": password, "email_verified": true, "updated_at": updatedAt}}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resetting password"})
+			return
+		}
+
+		if err := helpers.RevokeUserSessions(user.User_id); err != nil {
+			log.Println(err)
+		}
+		if err := revokeUserAPIKeys(ctx, user.User_id); err != nil {
+			log.Println(err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password has been reset"})
+	}
+}
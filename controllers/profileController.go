@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, user.Public())
+	}
+}
+
+func UpdateMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			First_Name *string `json:"first_name"`
+			Last_Name  *string `json:"last_name"`
+			Phone      *string `json:"phone"`
+			Email      *string `json:"email"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Email != nil {
+			*body.Email = helpers.NormalizeEmail(*body.Email)
+		}
+
+		var current models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&current)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate only the submitted fields, against the same tags that
+		// models.User uses at signup.
+		changes := models.User{First_Name: body.First_Name, Last_Name: body.Last_Name, Phone: body.Phone, Email: body.Email}
+		set := bson.M{}
+		var fields []string
+		if body.First_Name != nil {
+			fields = append(fields, "First_Name")
+			set["first_name"] = body.First_Name
+		}
+		if body.Last_Name != nil {
+			fields = append(fields, "Last_Name")
+			set["last_name"] = body.Last_Name
+		}
+		if body.Phone != nil {
+			fields = append(fields, "Phone")
+			set["phone"] = body.Phone
+		}
+		emailChanged := body.Email != nil && *body.Email != *current.Email
+		if emailChanged {
+			fields = append(fields, "Email")
+		}
+
+		if len(fields) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no changes provided"})
+			return
+		}
+
+		validation := validate.StructPartial(changes, fields...)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		// A new email only replaces the current one once it is confirmed
+		// through the link sent to it.
+		var verificationToken string
+		if emailChanged {
+			count, err := userCollection.CountDocuments(ctx, bson.M{"email": body.Email})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for email"})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "this email already exists"})
+				return
+			}
+
+			// The unique index on pending_email stops two accounts waiting to
+			// confirm the same address. A claim whose link has expired no
+			// longer holds the address.
+			expired := bson.M{"pending_email": body.Email, "email_verification_expires_at": bson.M{"$lte": time.Now()}}
+			if _, err := userCollection.UpdateMany(ctx, expired, bson.M{"$unset": bson.M{"pending_email": ""}}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for email"})
+				return
+			}
+
+			current.Pending_email = body.Email
+			verificationToken, err = setEmailVerification(&current)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating verification token"})
+				return
+			}
+			set["pending_email"] = body.Email
+			set["email_verification_hash"] = current.Email_verification_hash
+			set["email_verification_expires_at"] = current.Email_verification_expires_at
+		}
+
+		set["updated_at"], _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		var user models.User
+		opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": current.User_id}, bson.M{"$set": set}, opt).Decode(&user)
+		if field := duplicateKeyField(err, "phone", "pending_email"); field != "" {
+			if field == "pending_email" {
+				field = "email"
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "this " + field + " already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if emailChanged {
+			sendVerificationEmail(user, verificationToken)
+		}
+		c.JSON(http.StatusOK, user.Public())
+	}
+}
+
+func ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Current_password *string `json:"current_password"`
+			New_password     *string `json:"new_password" validate:"required,min=8,max=16"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		// Accounts created through single sign-on have no password yet and
+		// can set one without a current password.
+		if user.Password != nil {
+			if wait := helpers.LoginRetryAfter(helpers.AccountLoginKey(*user.Email), helpers.IPLoginKey(c.ClientIP())); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, please try again later"})
+				return
+			}
+
+			if body.Current_password == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "current_password is required"})
+				return
+			}
+
+			if ok, _ := VerifyPassword(*body.Current_password, *user.Password); !ok {
+				helpers.RecordLoginFailure(*user.Email, c.ClientIP())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "the current password is incorrect"})
+				return
+			}
+		}
+
+		password := HashPassword(*body.New_password)
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update := bson.M{"$set": bson.M{"password": password, "updated_at": updatedAt}}
+
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while changing password"})
+			return
+		}
+
+		// API keys are revoked, since one may have been made by whoever knew
+		// the old password.
+		if err := revokeUserAPIKeys(ctx, user.User_id); err != nil {
+			log.Println(err)
+		}
+
+		// Log out every other device; the one making the change stays in.
+		sessions, err := helpers.ListSessions(user.User_id)
+		if err != nil {
+			log.Println(err)
+		}
+		for _, session := range sessions {
+			if session.Session_id == c.GetString("sid") {
+				continue
+			}
+			if err := helpers.RevokeSession(session.Session_id); err != nil {
+				log.Println(err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+	}
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var enrollmentCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "enrollment")
+var completionCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "completion")
+
+func EnrollInPlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Start         *string  `json:"start"`
+			Weekdays_only bool     `json:"weekdays_only"`
+			Rest_days     []string `json:"rest_days"`
+		}
+		// The body is optional; without it the plan starts today.
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		start := time.Now().UTC().Format("2006-01-02")
+		if body.Start != nil {
+			if _, err := time.Parse("2006-01-02", *body.Start); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "start must be a date in YYYY-MM-DD form"})
+				return
+			}
+			start = *body.Start
+		}
+
+		_, restNames, err := models.ParseRestDays(strings.Join(body.Rest_days, ","), body.Weekdays_only)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		planId := c.Param("study_plan")
+		count, err := planCollection.CountDocuments(ctx, bson.M{"plan_id": planId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while enrolling"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "study plan not found"})
+			return
+		}
+
+		enrolledAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		enrollment := models.Enrollment{
+			ID:            primitive.NewObjectID(),
+			User_id:       c.GetString("uid"),
+			Plan_id:       planId,
+			Start:         start,
+			Weekdays_only: body.Weekdays_only,
+			Rest_days:     restNames,
+			Enrolled_at:   enrolledAt,
+		}
+		enrollment.Enrollment_id = enrollment.ID.Hex()
+
+		_, err = enrollmentCollection.InsertOne(ctx, enrollment)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "you are already enrolled in this study plan"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Enrollment was not created"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, enrollment.Response())
+	}
+}
+
+// CompleteMaterial marks a material done for the caller. minutes_spent
+// defaults to the material's duration and completed_at to now.
+func CompleteMaterial() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Minutes_spent *int64     `json:"minutes_spent" validate:"omitempty,gte=0,lte=1440"`
+			Completed_at  *time.Time `json:"completed_at"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		completedAt := now
+		if body.Completed_at != nil {
+			if body.Completed_at.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "completed_at cannot be in the future"})
+				return
+			}
+			completedAt = body.Completed_at.UTC()
+		}
+
+		var material models.Study_Material
+		err := materialCollection.FindOne(ctx, bson.M{"material_id": c.Param("study_material")}).Decode(&material)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study material not found"})
+			return
+		}
+
+		minutesSpent := material.Time_Duration.Minutes()
+		if body.Minutes_spent != nil {
+			minutesSpent = *body.Minutes_spent
+		}
+
+		userId := c.GetString("uid")
+		update := bson.M{
+			"$set":         bson.M{"minutes_spent": minutesSpent, "completed_at": completedAt},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		}
+		upsert := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var completion models.Completion
+		err = completionCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId, "material_id": material.Material_Id}, update, upsert).Decode(&completion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while saving completion"})
+			return
+		}
+
+		c.JSON(http.StatusOK, completion.Response())
+	}
+}
+
+// userProgress reports on every plan the user is enrolled in.
+func userProgress(ctx context.Context, userId string) ([]models.Plan_Progress, error) {
+	enrollments, err := findAll[models.Enrollment](ctx, enrollmentCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	completionList, err := findAll[models.Completion](ctx, completionCollection, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	completions := map[string]models.Completion{}
+	for _, completion := range completionList {
+		completions[completion.Material_id] = completion
+	}
+
+	today := time.Now().UTC()
+	progress := []models.Plan_Progress{}
+	for _, enrollment := range enrollments {
+		plan, err := findExpandedPlan(ctx, enrollment.Plan_id)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, enrollment.Progress(plan, completions, today))
+	}
+	return progress, nil
+}
+
+func GetMyProgress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		progress, err := userProgress(ctx, c.GetString("uid"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving progress"})
+			return
+		}
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// studentFilter matches the students whose progress the caller may read.
+// Anyone who can read users sees every student, mentors only the students
+// assigned to them.
+func studentFilter(c *gin.Context) bson.M {
+	if helpers.CheckPermission(c, helpers.PermUserRead) == nil {
+		return bson.M{}
+	}
+	return bson.M{"mentor_id": c.GetString("uid")}
+}
+
+// GetUserProgress lets mentors and admins follow a student.
+func GetUserProgress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		filter := studentFilter(c)
+		filter["user_id"] = userId
+		count, err := userCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving progress"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		progress, err := userProgress(ctx, userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving progress"})
+			return
+		}
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// GetMyStudents lists the students assigned to the calling mentor.
+func GetMyStudents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		students, err := findAll[models.User](ctx, userCollection, bson.M{"mentor_id": c.GetString("uid"), "deleted_at": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving students"})
+			return
+		}
+
+		responses := []models.Public_User{}
+		for _, student := range students {
+			responses = append(responses, student.Public())
+		}
+		c.JSON(http.StatusOK, responses)
+	}
+}
+
+// AssignMentor sets or, with an empty mentor_id, clears the mentor of a
+// student. The mentor must be an active user whose role can read progress.
+func AssignMentor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var body struct {
+			Mentor_id string `json:"mentor_id"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if status, err := checkTargetRank(ctx, c, userId); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update := bson.M{"$set": bson.M{"updated_at": now}, "$unset": bson.M{"mentor_id": ""}}
+		if body.Mentor_id != "" {
+			if body.Mentor_id == userId {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a user cannot mentor themselves"})
+				return
+			}
+
+			var mentor models.User
+			err := userCollection.FindOne(ctx, bson.M{"user_id": body.Mentor_id, "deleted_at": nil, "deactivated_at": nil}).Decode(&mentor)
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "mentor not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for mentor"})
+				return
+			}
+			if mentor.User_type == nil || !helpers.HasPermission(*mentor.User_type, helpers.PermProgressRead) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the mentor's role cannot read progress"})
+				return
+			}
+
+			update = bson.M{"$set": bson.M{"mentor_id": body.Mentor_id, "updated_at": now}}
+		}
+
+		var user models.User
+		opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err := userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId, "deleted_at": nil}, update, opt).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusOK, user.Admin())
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// unknownIds returns the ids that no document in collection has in field.
+func unknownIds(ctx context.Context, collection *mongo.Collection, field string, ids []string) ([]string, error) {
+	unknown := []string{}
+	if len(ids) == 0 {
+		return unknown, nil
+	}
+
+	found, err := collection.Distinct(ctx, field, bson.M{field: bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, id := range found {
+		if s, ok := id.(string); ok {
+			known[s] = true
+		}
+	}
+	for _, id := range ids {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown, nil
+}
+
+// checkReferences responds with 400 and returns false when some of ids are
+// not the idField of any document in collection.
+func checkReferences(ctx context.Context, c *gin.Context, collection *mongo.Collection, idField string, ids []string) bool {
+	unknown, err := unknownIds(ctx, collection, idField, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking " + idField + "s"})
+		return false
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown " + idField + " " + strings.Join(unknown, ", ")})
+		return false
+	}
+	return true
+}
+
+// lookupIds joins the documents whose idField is listed in localField into
+// as. $lookup returns them in no particular order and only once each, so the
+// handlers put them back in the order of the list with OrderMaterials and
+// OrderCourses.
+func lookupIds(from string, localField string, idField string, as string, nested mongo.Pipeline) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$expr": bson.M{"$in": bson.A{"$" + idField, "$$ids"}}}}},
+	}
+	pipeline = append(pipeline, nested...)
+
+	return mongo.Pipeline{
+		{{Key: "$addFields", Value: bson.M{localField: bson.M{"$ifNull": bson.A{"$" + localField, bson.A{}}}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":     from,
+			"let":      bson.M{"ids": "$" + localField},
+			"pipeline": pipeline,
+			"as":       as,
+		}}},
+	}
+}
+
+func expandMaterials() mongo.Pipeline {
+	return lookupIds("study_material", "material_ids", "material_id", "course_materials", nil)
+}
+
+// planExpandStages reads ?expand= for a plan: "courses" adds the courses and
+// "courses.materials" adds their materials as well.
+func planExpandStages(expand string) mongo.Pipeline {
+	var nested mongo.Pipeline
+	switch expand {
+	case "courses":
+	case "courses.materials":
+		nested = expandMaterials()
+	default:
+		return nil
+	}
+	return lookupIds("course", "course_ids", "course_id", "course", nested)
+}
+
+// courseExpandStages reads ?expand= for a course: "materials" adds them.
+func courseExpandStages(expand string) mongo.Pipeline {
+	if expand != "materials" {
+		return nil
+	}
+	return expandMaterials()
+}
+
+// findOneExpanded decodes the document matching filter into result, run
+// through the expand stages.
+func findOneExpanded(ctx context.Context, collection *mongo.Collection, filter bson.M, stages mongo.Pipeline, result interface{}) error {
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: filter}}, {{Key: "$limit", Value: 1}}}, stages...)
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		return mongo.ErrNoDocuments
+	}
+	return cursor.Decode(result)
+}
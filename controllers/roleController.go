@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var roleCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "role")
+var roleChangeCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "role_change")
+
+func GetRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		roles := []models.Role_Response{}
+
+		cursor, err := roleCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving roles"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var role models.Role
+			cursor.Decode(&role)
+			roles = append(roles, role.Response())
+		}
+
+		c.JSON(http.StatusOK, gin.H{"roles": roles, "permissions": helpers.Permissions})
+	}
+}
+
+func PutRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		name := c.Param("role")
+		var role models.Role
+
+		if err := c.BindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		role.Name = &name
+
+		validation := validate.Struct(role)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		for _, permission := range role.Permissions {
+			if !helpers.IsKnownPermission(permission) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown permission " + permission})
+				return
+			}
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		opt := options.UpdateOptions{
+			Upsert: &upsert,
+		}
+		update := bson.M{
+			"$set":         bson.M{"permissions": role.Permissions, "updated_at": now},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": now},
+		}
+
+		_, err := roleCollection.UpdateOne(ctx, bson.M{"name": name}, update, &opt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "role was not saved"})
+			return
+		}
+
+		// Built-in roles never lose their default permissions.
+		if err := helpers.EnsureDefaultRoles(); err != nil {
+			log.Println(err)
+		}
+
+		err = roleCollection.FindOne(ctx, bson.M{"name": name}).Decode(&role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, role.Response())
+	}
+}
+
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var body struct {
+			User_type *string `json:"user_type" validate:"required,role"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		// Nobody may hand out a role above their own.
+		if err := helpers.CheckRoleRank(c, *body.User_type); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		changedBy := c.GetString("uid")
+		if changedBy == userId {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot change your own role"})
+			return
+		}
+
+		if status, err := checkTargetRank(ctx, c, userId); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := changeUserRole(ctx, userId, *body.User_type, changedBy)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while updating role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "user_type": body.User_type})
+	}
+}
+
+// checkTargetRank loads a user that the caller wants to manage and returns
+// an error with its response status unless the caller's role ranks at least
+// as high as the user's current role.
+func checkTargetRank(ctx context.Context, c *gin.Context, userId string) (int, error) {
+	var target models.User
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&target)
+	if err == mongo.ErrNoDocuments {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("error occured while checking for user")
+	}
+
+	if target.User_type != nil {
+		if err := helpers.CheckRoleRank(c, *target.User_type); err != nil {
+			return http.StatusForbidden, err
+		}
+	}
+	return 0, nil
+}
+
+// changeUserRole sets the role of one user, records the change and ends the
+// user's sessions. It returns mongo.ErrNoDocuments when the user is missing.
+// Callers check that changedBy may grant userType and outranks the user.
+func changeUserRole(ctx context.Context, userId string, userType string, changedBy string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.M{"$set": bson.M{
+		"user_type":       userType,
+		"role_updated_by": changedBy,
+		"role_updated_at": updatedAt,
+		"updated_at":      updatedAt,
+	}}
+
+	var previous models.User
+	err := userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId}, update).Decode(&previous)
+	if err != nil {
+		return err
+	}
+
+	change := models.Role_Change{
+		ID:         primitive.NewObjectID(),
+		User_id:    userId,
+		Changed_by: changedBy,
+		From:       previous.User_type,
+		To:         &userType,
+		Changed_at: updatedAt,
+	}
+	if _, err := roleChangeCollection.InsertOne(ctx, change); err != nil {
+		log.Println(err)
+	}
+
+	// Tokens carry the role, so end the user's sessions to make them log in
+	// again and pick it up.
+	if err := helpers.RevokeUserSessions(userId); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
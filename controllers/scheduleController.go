@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"Gate/models"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// findExpandedPlan loads a plan with its courses and their materials.
+func findExpandedPlan(ctx context.Context, planId string) (models.Study_Plan, error) {
+	var plan models.Study_Plan
+	err := findOneExpanded(ctx, planCollection, bson.M{"plan_id": planId}, planExpandStages("courses.materials"), &plan)
+	plan.OrderCourses()
+	return plan, err
+}
+
+func GetPlanFeasibility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		plan, err := findExpandedPlan(ctx, c.Param("study_plan"))
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study plan not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan.Feasibility())
+	}
+}
+
+// GetStudySchedule lays the plan out day by day from ?start= (today by
+// default). ?weekdays_only=true skips weekends and ?rest_days= takes a list
+// of weekdays or dates to skip as well.
+func GetStudySchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		start := time.Now().UTC().Truncate(24 * time.Hour)
+		if value := c.Query("start"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "start must be a date in YYYY-MM-DD form"})
+				return
+			}
+			start = parsed
+		}
+
+		weekdaysOnly := false
+		if value := c.Query("weekdays_only"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "weekdays_only must be true or false"})
+				return
+			}
+			weekdaysOnly = parsed
+		}
+
+		rest, restNames, err := models.ParseRestDays(c.Query("rest_days"), weekdaysOnly)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		plan, err := findExpandedPlan(ctx, c.Param("study_plan"))
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "study plan not found"})
+			return
+		}
+		if plan.Daily_Minutes <= 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the study plan has no daily minutes to schedule"})
+			return
+		}
+
+		schedule := plan.Schedule(start, weekdaysOnly, rest, restNames)
+		c.JSON(http.StatusOK, schedule)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"Gate/database"
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var sessionCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "session")
+
+func GetSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := helpers.ListSessions(c.GetString("uid"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving sessions"})
+			return
+		}
+
+		current := c.GetString("sid")
+		responses := []models.Session_Response{}
+		for _, session := range sessions {
+			session.Current = session.Session_id == current
+			responses = append(responses, session.Response())
+		}
+
+		c.JSON(http.StatusOK, responses)
+	}
+}
+
+func DeleteSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		sessionId := c.Param("session_id")
+
+		count, err := sessionCollection.CountDocuments(ctx, bson.M{"session_id": sessionId, "user_id": c.GetString("uid"), "revoked_at": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for session"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+
+		if err := helpers.RevokeSession(sessionId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+	}
+}
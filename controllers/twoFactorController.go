@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const recoveryCodeCount = 10
+
+func SetupTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Totp_enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := helpers.GenerateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating secret"})
+			return
+		}
+
+		// The secret stays pending until a code generated from it is confirmed.
+		update := bson.M{"$set": bson.M{"totp_secret": secret, "totp_enabled": false, "totp_last_step": 0}}
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while saving secret"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": helpers.TOTPURI(secret, *user.Email)})
+	}
+}
+
+func ConfirmTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Code *string `json:"code" validate:"required,numeric,len=6"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Totp_enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		if user.Totp_secret == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor setup has not been started"})
+			return
+		}
+
+		step, ok := helpers.ValidateTOTP(*user.Totp_secret, *body.Code, time.Now(), user.Totp_last_step)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the code is invalid"})
+			return
+		}
+
+		codes, hashes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating recovery codes"})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update := bson.M{"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+			"recovery_codes": hashes,
+			"updated_at":     updatedAt,
+		}}
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while enabling two-factor authentication"})
+			return
+		}
+
+		// Recovery codes are only ever shown here; the user must store them.
+		c.JSON(http.StatusOK, gin.H{"totp_enabled": true, "recovery_codes": codes})
+	}
+}
+
+func VerifyTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Mfa_token     *string `json:"mfa_token" validate:"required"`
+			Code          string  `json:"code" validate:"required_without=Recovery_code"`
+			Recovery_code string  `json:"recovery_code"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		claims, msg := helpers.ValidateTokens(*body.Mfa_token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		if claims.Token_type != helpers.MFAToken || helpers.IsTokenRevoked(claims.Id) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa token is invalid"})
+			return
+		}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid, "deleted_at": nil, "deactivated_at": nil}).Decode(&user)
+		if err != nil || !user.Totp_enabled || user.Totp_secret == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa token is invalid"})
+			return
+		}
+
+		// Codes are short, so failed guesses count towards the same lockout
+		// as failed passwords.
+		if wait := helpers.LoginRetryAfter(helpers.AccountLoginKey(*user.Email), helpers.IPLoginKey(c.ClientIP())); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, please try again later"})
+			return
+		}
+
+		if body.Code != "" {
+			step, ok := helpers.ValidateTOTP(*user.Totp_secret, body.Code, time.Now(), user.Totp_last_step)
+			if !ok {
+				helpers.RecordLoginFailure(*user.Email, c.ClientIP())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "the code is invalid"})
+				return
+			}
+
+			// Moving totp_last_step forward only if it is still behind makes
+			// each code usable once, even across concurrent requests.
+			filter := bson.M{"user_id": user.User_id, "totp_last_step": bson.M{"$lt": step}}
+			result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+			if err != nil || result.MatchedCount == 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "the code is invalid"})
+				return
+			}
+		} else {
+			hash := helpers.HashRecoveryCode(body.Recovery_code)
+			filter := bson.M{"user_id": user.User_id, "recovery_codes": hash}
+			result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+			if err != nil || result.MatchedCount == 0 {
+				helpers.RecordLoginFailure(*user.Email, c.ClientIP())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "the recovery code is invalid"})
+				return
+			}
+		}
+
+		if err := helpers.RevokeToken(claims.Id, claims.ExpiresAt); err != nil {
+			log.Println(err)
+		}
+
+		completeLogin(ctx, c, user)
+	}
+}
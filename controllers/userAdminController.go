@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// adminUserProjection only loads the fields of models.Admin_User, so password
+// hashes, secrets and tokens never leave the database.
+var adminUserProjection = bson.M{
+	"_id":             0,
+	"user_id":         1,
+	"first_name":      1,
+	"last_name":       1,
+	"email":           1,
+	"phone":           1,
+	"user_type":       1,
+	"email_verified":  1,
+	"pending_email":   1,
+	"totp_enabled":    1,
+	"created_at":      1,
+	"updated_at":      1,
+	"role_updated_by": 1,
+	"role_updated_at": 1,
+	"mentor_id":       1,
+	"deactivated_at":  1,
+	"deleted_at":      1,
+}
+
+var userSortFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"created_at": true,
+}
+
+// parseDateQuery accepts a full RFC 3339 time or a plain date.
+func parseDateQuery(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// userListFilter builds the user filter from the query string:
+//
+//	search          case-insensitive match on name, email or phone
+//	user_type       one role or a comma separated list
+//	email_verified  true or false
+//	created_from    created at or after, RFC 3339 or YYYY-MM-DD
+//	created_to      created before, RFC 3339 or YYYY-MM-DD
+//	status          active, deactivated or deleted
+func userListFilter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+
+	if search := c.Query("search"); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = []bson.M{
+			{"first_name": pattern},
+			{"last_name": pattern},
+			{"email": pattern},
+			{"phone": pattern},
+		}
+	}
+
+	if userType := c.Query("user_type"); userType != "" {
+		filter["user_type"] = bson.M{"$in": strings.Split(userType, ",")}
+	}
+
+	if verified := c.Query("email_verified"); verified != "" {
+		isVerified, err := strconv.ParseBool(verified)
+		if err != nil {
+			return nil, errors.New("email_verified must be true or false")
+		}
+		// Accounts from before email verification have no flag and count
+		// as verified.
+		if isVerified {
+			filter["email_verified"] = bson.M{"$ne": false}
+		} else {
+			filter["email_verified"] = false
+		}
+	}
+
+	created := bson.M{}
+	if from := c.Query("created_from"); from != "" {
+		t, err := parseDateQuery(from)
+		if err != nil {
+			return nil, errors.New("created_from must be a date or an RFC 3339 time")
+		}
+		created["$gte"] = t
+	}
+	if to := c.Query("created_to"); to != "" {
+		t, err := parseDateQuery(to)
+		if err != nil {
+			return nil, errors.New("created_to must be a date or an RFC 3339 time")
+		}
+		created["$lt"] = t
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+
+	switch c.Query("status") {
+	case "":
+	case "active":
+		filter["deleted_at"] = nil
+		filter["deactivated_at"] = nil
+	case "deactivated":
+		filter["deleted_at"] = nil
+		filter["deactivated_at"] = bson.M{"$ne": nil}
+	case "deleted":
+		filter["deleted_at"] = bson.M{"$ne": nil}
+	default:
+		return nil, errors.New("status must be active, deactivated or deleted")
+	}
+
+	return filter, nil
+}
+
+// userListSort reads sort_by (default first_name) and sort (ASC or DESC).
+func userListSort(c *gin.Context) bson.D {
+	field := c.DefaultQuery("sort_by", "first_name")
+	if !userSortFields[field] {
+		field = "first_name"
+	}
+	order := 1
+	if c.Query("sort") == "DESC" {
+		order = -1
+	}
+	return bson.D{{Key: field, Value: order}, {Key: "user_id", Value: 1}}
+}
+
+func ExportUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter, err := userListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		findOptions := options.Find().SetProjection(adminUserProjection).SetSort(userListSort(c))
+		cursor, err := userCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving users"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"user_id", "first_name", "last_name", "email", "phone", "user_type", "email_verified", "totp_enabled", "created_at", "deactivated_at", "deleted_at"})
+		for cursor.Next(ctx) {
+			var user models.Admin_User
+			if err := cursor.Decode(&user); err != nil {
+				log.Println(err)
+				continue
+			}
+			w.Write([]string{
+				user.User_id,
+				csvString(user.First_Name),
+				csvString(user.Last_Name),
+				csvString(user.Email),
+				csvString(user.Phone),
+				csvString(user.User_type),
+				strconv.FormatBool(user.Email_verified == nil || *user.Email_verified),
+				strconv.FormatBool(user.Totp_enabled),
+				user.Created_at.Format(time.RFC3339),
+				csvTime(user.Deactivated_at),
+				csvTime(user.Deleted_at),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// csvString guards against formula injection when the file is opened in a
+// spreadsheet.
+func csvString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	if *value != "" && strings.ContainsRune("=+-@\t\r", rune((*value)[0])) {
+		return "'" + *value
+	}
+	return *value
+}
+
+func csvTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
+
+func BulkUpdateUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var body struct {
+			Action    string   `json:"action" validate:"required,oneof=deactivate reactivate change_role"`
+			User_ids  []string `json:"user_ids" validate:"required,min=1,max=500,dive,required"`
+			User_type *string  `json:"user_type" validate:"required_if=Action change_role,omitempty,role"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
+			return
+		}
+
+		if body.Action == "change_role" {
+			if err := helpers.CheckRoleRank(c, *body.User_type); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		changedBy := c.GetString("uid")
+		updated := []string{}
+		failed := map[string]string{}
+
+		for _, userId := range body.User_ids {
+			if userId == changedBy {
+				failed[userId] = "you cannot change your own account"
+				continue
+			}
+			if _, err := checkTargetRank(ctx, c, userId); err != nil {
+				failed[userId] = err.Error()
+				continue
+			}
+
+			var err error
+			switch body.Action {
+			case "deactivate":
+				err = deactivateUser(ctx, userId)
+			case "reactivate":
+				err = reactivateUser(ctx, userId)
+			case "change_role":
+				err = changeUserRole(ctx, userId, *body.User_type, changedBy)
+			}
+
+			if err == mongo.ErrNoDocuments {
+				failed[userId] = "user not found or already in this state"
+				continue
+			}
+			if err != nil {
+				log.Println(err)
+				failed[userId] = "error occured while updating user"
+				continue
+			}
+			updated = append(updated, userId)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"updated": updated, "failed": failed})
+	}
+}
+
+// deactivateUser blocks logins, refreshes and API keys for the user and ends
+// every session. Unlike a delete, no data is removed and it can be undone.
+func deactivateUser(ctx context.Context, userId string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	filter := bson.M{"user_id": userId, "deleted_at": nil, "deactivated_at": nil}
+	update := bson.M{"$set": bson.M{"deactivated_at": now, "updated_at": now}}
+
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return helpers.RevokeUserSessions(userId)
+}
+
+func reactivateUser(ctx context.Context, userId string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	filter := bson.M{"user_id": userId, "deleted_at": nil, "deactivated_at": bson.M{"$ne": nil}}
+	update := bson.M{"$set": bson.M{"updated_at": now}, "$unset": bson.M{"deactivated_at": ""}}
+
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
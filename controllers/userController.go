@@ -7,8 +7,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +22,17 @@ import (
 )
 
 var userCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "user")
-var validate = validator.New()
+var validate = newValidator()
+
+const defaultUserType = "USER"
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+		return helpers.RoleExists(fl.Field().String())
+	})
+	return v
+}
 
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 16)
@@ -37,18 +49,53 @@ func VerifyPassword(userPass string, providedPass string) (bool, string) {
 	msg := ""
 
 	if err != nil {
-		msg = fmt.Sprintf("email of password is incorrect")
+		msg = fmt.Sprintf("email or password is incorrect")
 		check = false
 	}
 	return check, msg
 }
 
+var dummyHash struct {
+	once sync.Once
+	hash string
+}
+
+// dummyPasswordHash returns a hash with the same cost as real ones, compared
+// against when the email is unknown.
+func dummyPasswordHash() string {
+	dummyHash.once.Do(func() {
+		dummyHash.hash = HashPassword(primitive.NewObjectID().Hex())
+	})
+	return dummyHash.hash
+}
+
+func UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.Param("user_id")}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if err := helpers.ResetLoginFailures(*user.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while unlocking user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+	}
+}
+
 func GetUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.Param("user_id")
 
 		if err := helpers.MatchUserUID(c, userId); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
 		}
 
@@ -58,22 +105,51 @@ func GetUser() gin.HandlerFunc {
 		err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
 		defer cancel()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(findErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if helpers.CheckPermission(c, helpers.PermUserRead) == nil {
+			c.JSON(http.StatusOK, user.Admin())
 			return
 		}
-		c.JSON(http.StatusOK, user)
+		c.JSON(http.StatusOK, user.Public())
 	}
 }
 
 func SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		var user models.User
+		defer cancel()
+		// Only these fields are read from the body, so a signup cannot set
+		// its role, two-factor, deactivation or deletion state.
+		var body struct {
+			First_Name *string `json:"first_name"`
+			Last_Name  *string `json:"last_name"`
+			Password   *string `json:"password"`
+			Email      *string `json:"email"`
+			Phone      *string `json:"phone"`
+		}
 
-		if err := c.BindJSON(&user); err != nil {
+		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if body.Email != nil {
+			*body.Email = helpers.NormalizeEmail(*body.Email)
+		}
+
+		// Public signup always creates a student. Other roles are granted by
+		// an admin through PATCH admin/users/:user_id/role.
+		userType := defaultUserType
+		user := models.User{
+			First_Name: body.First_Name,
+			Last_Name:  body.Last_Name,
+			Password:   body.Password,
+			Email:      body.Email,
+			Phone:      body.Phone,
+			User_type:  &userType,
+		}
 
 		validation := validate.Struct(user)
 		if validation != nil {
@@ -81,50 +157,34 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
-		defer cancel()
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for email"})
-		}
-
-		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this email already exists"})
-		}
-
 		password := HashPassword(*user.Password)
 		user.Password = &password
 
-		count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
-		defer cancel()
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for phone"})
-		}
-
-		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this phone already exists"})
-		}
-
 		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
-		token, refreshToken, err := helpers.GenerateTokens(*user.Email, *user.First_Name, *user.Last_Name, *user.User_type, *&user.User_id)
+
+		verificationToken, err := setEmailVerification(&user)
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating verification token"})
+			return
 		}
 
-		user.Token = &token
-		user.Refresh_token = &refreshToken
-
-		insertNumber, errInsert := userCollection.InsertOne(ctx, user)
+		// The unique indexes on email and phone reject duplicates atomically,
+		// even when two signups race.
+		_, errInsert := userCollection.InsertOne(ctx, user)
+		if field := duplicateKeyField(errInsert, "email", "phone"); field != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "this " + field + " already exists"})
+			return
+		}
 		if errInsert != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User iter was not created"})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, insertNumber)
+
+		sendVerificationEmail(user, verificationToken)
+		c.JSON(http.StatusOK, user.Public())
 	}
 
 }
@@ -132,6 +192,7 @@ func SignUp() gin.HandlerFunc {
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var user models.User
 		var userFound models.User
 
@@ -140,48 +201,202 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		if user.Email == nil || user.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+			return
+		}
+		*user.Email = helpers.NormalizeEmail(*user.Email)
+
+		if wait := helpers.LoginRetryAfter(helpers.AccountLoginKey(*user.Email), helpers.IPLoginKey(c.ClientIP())); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, please try again later"})
+			return
+		}
+
+		// Unknown emails and wrong passwords take the same time and get the
+		// same answer, so the response does not reveal which emails exist.
 		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&userFound)
-		defer cancel()
+		storedPassword := dummyPasswordHash()
+		if err == nil && userFound.Password != nil {
+			storedPassword = *userFound.Password
+		}
+
+		isPasswordValid, _ := VerifyPassword(*user.Password, storedPassword)
+		if err != nil || userFound.Password == nil || userFound.Deleted_at != nil || !isPasswordValid {
+			if err := helpers.RecordLoginFailure(*user.Email, c.ClientIP()); err != nil {
+				log.Println(err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "email or password is incorrect"})
+			return
+		}
+
+		if userFound.Email == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "email or password is incorrect"})
+			return
+		}
+
+		startLogin(ctx, c, userFound)
+	}
+}
+
+// startLogin finishes a first-factor login. Users with two-factor
+// authentication get an mfa token to exchange at users/2fa/verify, everyone
+// else gets their tokens right away.
+func startLogin(ctx context.Context, c *gin.Context, userFound models.User) {
+	if userFound.Deactivated_at != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account has been deactivated"})
+		return
+	}
+
+	if userFound.Totp_enabled {
+		mfaToken, err := helpers.GenerateMFAToken(userFound)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "email or password is incorrect"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating token"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	completeLogin(ctx, c, userFound)
+}
 
-		isPasswordValid, msg := VerifyPassword(*user.Password, *userFound.Password)
+// completeLogin starts a new session for a user whose credentials have been
+// checked and responds with the user and the session's tokens. The failed
+// login count is only cleared here, once every factor has been checked, so
+// a correct password alone does not reset the lockout on second factor
+// guesses.
+func completeLogin(ctx context.Context, c *gin.Context, userFound models.User) {
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userFound.User_id}).Decode(&userFound)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if userFound.Email != nil {
+		if err := helpers.ResetLoginFailures(*userFound.Email); err != nil {
+			log.Println(err)
+		}
+	}
+
+	token, refreshToken, err := helpers.CreateSession(userFound, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Login_Response{
+		Public_User:   userFound.Public(),
+		Token:         token,
+		Refresh_token: refreshToken,
+	})
+}
+
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		if !isPasswordValid {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+
+		var body struct {
+			Refresh_token *string `json:"refresh_token" validate:"required"`
+		}
+
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if userFound.Email == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		validation := validate.Struct(body)
+		if validation != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validation.Error()})
 			return
 		}
 
-		token, refreshToken, _ := helpers.GenerateTokens(*userFound.Email, *userFound.First_Name, *userFound.Last_Name, *userFound.User_type, userFound.User_id)
-		helpers.UpdateTokens(token, refreshToken, userFound.User_id)
-		err = userCollection.FindOne(ctx, bson.M{"user_id": userFound.User_id}).Decode(&userFound)
+		claims, msg := helpers.ValidateTokens(*body.Refresh_token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		if claims.Token_type != helpers.RefreshToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the token is not a refresh token"})
+			return
+		}
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid, "deleted_at": nil, "deactivated_at": nil}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		if claims.Session_id == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the refresh token has no session, please login again"})
+			return
+		}
 
+		// A validly signed refresh token that is no longer the session's
+		// current one has already been exchanged, so it may have been stolen.
+		// RotateSession then revokes the session and the user logs in again.
+		token, refreshToken, err := helpers.RotateSession(user, claims.Session_id, *body.Refresh_token, c.ClientIP())
+		if err == helpers.ErrRefreshTokenReused {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while updating token"})
 			return
 		}
-		c.JSON(http.StatusOK, userFound)
 
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
 	}
 }
 
-func GetUsers() gin.HandlerFunc {
+func Logout() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := helpers.RevokeToken(c.GetString("jti"), c.GetInt64("token_expires_at")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking token"})
+			return
+		}
+
+		if sessionId := c.GetString("sid"); sessionId != "" {
+			if err := helpers.RevokeSession(sessionId); err != nil && err != mongo.ErrNoDocuments {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking session"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
+
+func RevokeUserSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		if status, err := checkTargetRank(ctx, c, userId); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
 
-		var ctx, _ = context.WithTimeout(context.Background(), 100*time.Second)
+		if err := helpers.RevokeUserSessions(userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "sessions revoked"})
+	}
+}
+
+func GetUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var users []models.User
+		users := []models.Admin_User{}
 
 		page, err := strconv.Atoi(c.Query("page"))
 		if err != nil || page < 1 {
@@ -194,29 +409,35 @@ func GetUsers() gin.HandlerFunc {
 		}
 
 		findOptions := newPaginate(limit, page).getPaginatedOpts()
+		findOptions.SetProjection(adminUserProjection)
+		findOptions.SetSort(userListSort(c))
 
-		filter := bson.M{}
+		filter, err := userListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		if sort := c.Query("sort"); sort != "" {
-			if sort == "ASC" {
-				findOptions.SetSort(bson.D{{"first_name", 1}})
-			} else if sort == "DESC" {
-				findOptions.SetSort(bson.D{{"first_name", -1}})
-			}
+		total, err := userCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving users"})
+			return
 		}
 
 		cursor, err := userCollection.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while retreiving users"})
+			return
 		}
 		defer cursor.Close(ctx)
 
 		for cursor.Next(ctx) {
-			var user models.User
+			var user models.Admin_User
 			cursor.Decode(&user)
 			users = append(users, user)
 		}
 
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 		c.JSON(http.StatusOK, users)
 	}
 }
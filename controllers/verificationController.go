@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"Gate/helpers"
+	"Gate/models"
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const emailVerificationTTL = 48 * time.Hour
+
+// setEmailVerification stores the hash of a fresh verification token on the
+// user. The plain token is returned for the email. It confirms the pending
+// email when there is one and the current email otherwise.
+func setEmailVerification(user *models.User) (string, error) {
+	token, hash, err := helpers.NewOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	user.Email_verification_hash = &hash
+	user.Email_verification_expires_at = &expiresAt
+
+	return token, nil
+}
+
+// sendVerificationEmail sends the link to the address being verified: the
+// pending email during an email change, the account email otherwise.
+func sendVerificationEmail(user models.User, token string) {
+	to := *user.Email
+	if user.Pending_email != nil {
+		to = *user.Pending_email
+	}
+
+	link := helpers.AppURL("users/verify?token=" + url.QueryEscape(token))
+	helpers.SendMail(helpers.Mail{
+		To:      to,
+		Subject: "Verify your Gate email address",
+		Body:    "Hi " + *user.First_Name + ",\n\nPlease confirm your email address by opening the link below. It expires in 48 hours.\n\n" + link,
+	})
+}
+
+func VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		token := c.Query("token")
+		if token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		// Consume the token first so it cannot be used twice.
+		var user models.User
+		filter := bson.M{
+			"email_verification_hash":       helpers.HashOneTimeToken(token),
+			"email_verification_expires_at": bson.M{"$gt": time.Now()},
+		}
+		consume := bson.M{"$unset": bson.M{"email_verification_hash": "", "email_verification_expires_at": ""}}
+
+		err := userCollection.FindOneAndUpdate(ctx, filter, consume).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the verification token is invalid or expired"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while verifying email"})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		set := bson.M{"email_verified": true, "updated_at": updatedAt}
+		update := bson.M{"$set": set}
+		if user.Pending_email != nil {
+			set["email"] = *user.Pending_email
+			update["$unset"] = bson.M{"pending_email": ""}
+		}
+
+		// The unique index on email decides races between two accounts
+		// confirming the same address.
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this email already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while verifying email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+	}
+}
+
+func ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user)
+		if err != nil {
+			c.JSON(findErrorStatus(err), gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Email_verified && user.Pending_email == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is already verified"})
+			return
+		}
+
+		token, err := setEmailVerification(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating verification token"})
+			return
+		}
+
+		update := bson.M{"$set": bson.M{
+			"email_verification_hash":       user.Email_verification_hash,
+			"email_verification_expires_at": user.Email_verification_expires_at,
+		}}
+
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating verification token"})
+			return
+		}
+
+		sendVerificationEmail(user, token)
+		c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+	}
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type collectionIndexes struct {
+	collection string
+	indexes    []mongo.IndexModel
+}
+
+// uniqueIndex makes the combination of fields unique.
+func uniqueIndex(fields ...string) mongo.IndexModel {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(true),
+	}
+}
+
+// uniqueIfPresent only enforces uniqueness on documents where the field is a
+// string, so accounts without the field do not collide with each other.
+func uniqueIfPresent(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: field, Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{field: bson.M{"$type": "string"}}),
+	}
+}
+
+// expireAt removes documents once the time in field has passed.
+func expireAt(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+}
+
+func lookupIndex(field string) mongo.IndexModel {
+	return mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}}
+}
+
+var applicationIndexes = []collectionIndexes{
+	{"user", []mongo.IndexModel{uniqueIndex("email"), uniqueIfPresent("phone"), uniqueIfPresent("pending_email"), uniqueIndex("user_id"), lookupIndex("mentor_id")}},
+	{"study_material", []mongo.IndexModel{uniqueIndex("material_title"), uniqueIndex("material_id")}},
+	{"course", []mongo.IndexModel{uniqueIndex("course_name"), uniqueIndex("course_id"), lookupIndex("material_ids")}},
+	{"plan", []mongo.IndexModel{uniqueIndex("plan_name"), uniqueIndex("plan_id"), lookupIndex("course_ids")}},
+	{"role", []mongo.IndexModel{uniqueIndex("name")}},
+	{"session", []mongo.IndexModel{uniqueIndex("session_id"), lookupIndex("user_id"), expireAt("refresh_expires_at")}},
+	{"api_key", []mongo.IndexModel{uniqueIndex("key_hash"), lookupIndex("user_id")}},
+	{"revoked_token", []mongo.IndexModel{uniqueIndex("jti"), expireAt("expires_at")}},
+	{"login_attempt", []mongo.IndexModel{uniqueIndex("key")}},
+	{"enrollment", []mongo.IndexModel{uniqueIndex("user_id", "plan_id"), lookupIndex("plan_id"), uniqueIfPresent("calendar_token_hash")}},
+	{"completion", []mongo.IndexModel{uniqueIndex("user_id", "material_id")}},
+	{"import_job", []mongo.IndexModel{uniqueIndex("import_job_id")}},
+	{"oidc_state", []mongo.IndexModel{uniqueIndex("state_hash"), expireAt("expires_at")}},
+}
+
+// EnsureIndexes creates the indexes the application relies on for
+// uniqueness. Creating an index that already exists is a no-op, so this runs
+// on every start.
+//
+// Before a unique index is first created, the collection is checked for
+// documents that already break it. If there are any, the start fails with
+// their _ids; merge or delete those documents by hand and start again.
+func EnsureIndexes(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for _, c := range applicationIndexes {
+		collection := OpenOrCreateDB(client, c.collection)
+		existing, err := indexNames(ctx, collection)
+		if err != nil {
+			return err
+		}
+
+		var problems []string
+		for _, index := range c.indexes {
+			if existing[indexName(index)] || index.Options == nil || index.Options.Unique == nil || !*index.Options.Unique {
+				continue
+			}
+			found, err := findDuplicates(ctx, collection, index)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, found...)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("cannot create unique indexes on %s, these documents share a value; merge or delete them and start again:\n%s", c.collection, strings.Join(problems, "\n"))
+		}
+
+		if _, err := collection.Indexes().CreateMany(ctx, c.indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexNames(ctx context.Context, collection *mongo.Collection) (map[string]bool, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	names := map[string]bool{}
+	for cursor.Next(ctx) {
+		var index struct {
+			Name string `bson:"name"`
+		}
+		if err := cursor.Decode(&index); err != nil {
+			return nil, err
+		}
+		names[index.Name] = true
+	}
+	return names, cursor.Err()
+}
+
+// indexName is the default name the server gives an index, such as
+// "user_id_1_plan_id_1".
+func indexName(index mongo.IndexModel) string {
+	parts := []string{}
+	for _, key := range index.Keys.(bson.D) {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// findDuplicates describes every group of documents that share the fields
+// of a unique index, listing the values and the _ids of the documents.
+func findDuplicates(ctx context.Context, collection *mongo.Collection, index mongo.IndexModel) ([]string, error) {
+	match := bson.M{}
+	if index.Options.PartialFilterExpression != nil {
+		match = index.Options.PartialFilterExpression.(bson.M)
+	}
+	group := bson.M{}
+	for _, key := range index.Keys.(bson.D) {
+		group[key.Key] = "$" + key.Key
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": group, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 50}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var problems []string
+	for cursor.Next(ctx) {
+		var duplicate struct {
+			Values bson.M        `bson:"_id"`
+			Ids    []interface{} `bson:"ids"`
+		}
+		if err := cursor.Decode(&duplicate); err != nil {
+			return nil, err
+		}
+		problems = append(problems, fmt.Sprintf("  %s %v: _id %v", indexName(index), duplicate.Values, duplicate.Ids))
+	}
+	return problems, cursor.Err()
+}
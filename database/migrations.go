@@ -0,0 +1,400 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migration is a one-time change to stored data. Migrations work on plain
+// bson documents rather than the models, so they keep working after the
+// models change.
+type migration struct {
+	id string
+	up func(ctx context.Context) error
+}
+
+// applicationMigrations run in order. Append new migrations; never reorder,
+// rename or edit ones that have shipped.
+var applicationMigrations = []migration{
+	{"001_course_material_references", referenceCourseMaterials},
+	{"002_plan_course_references", referencePlanCourses},
+	{"003_duration_rollup", rollUpDurations},
+	{"004_normalise_user_emails", normaliseUserEmails},
+}
+
+const (
+	migrationTimeout = 10 * time.Minute
+	// migrationLease is how long a claim without applied_at is honoured.
+	// It outlasts migrationTimeout, so an older claim belongs to an
+	// instance that crashed and the migration is run again. Migrations only
+	// change documents that still need it, so running one twice is safe.
+	migrationLease = 15 * time.Minute
+	migrationPoll  = 5 * time.Second
+)
+
+// RunMigrations applies the migrations that have not run yet and records each
+// one in the migrations collection. An instance claims a migration before
+// running it, so when several start together only one runs it and the
+// others wait for it to finish. A failed migration releases its claim and
+// stops the start.
+func RunMigrations(client *mongo.Client) error {
+	applied := OpenOrCreateDB(client, "migrations")
+	for _, m := range applicationMigrations {
+		claimed, err := claimMigration(applied, m.id)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		log.Println("running migration", m.id)
+		if err := runMigration(applied, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimMigration returns true when this instance should run the migration.
+// It waits while another instance holds a live claim, and returns false once
+// the migration has been applied.
+func claimMigration(applied *mongo.Collection, id string) (bool, error) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		claimed, done, err := tryClaimMigration(ctx, applied, id)
+		cancel()
+		if err != nil || claimed || done {
+			return claimed, err
+		}
+
+		log.Println("waiting for migration", id, "to finish in another instance")
+		time.Sleep(migrationPoll)
+	}
+}
+
+func tryClaimMigration(ctx context.Context, applied *mongo.Collection, id string) (claimed bool, done bool, err error) {
+	_, err = applied.InsertOne(ctx, bson.M{"_id": id, "started_at": time.Now()})
+	if err == nil {
+		return true, false, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, false, err
+	}
+
+	var claim struct {
+		Started_at time.Time  `bson:"started_at"`
+		Applied_at *time.Time `bson:"applied_at"`
+	}
+	if err := applied.FindOne(ctx, bson.M{"_id": id}).Decode(&claim); err != nil {
+		return false, false, err
+	}
+	if claim.Applied_at != nil {
+		return false, true, nil
+	}
+	if time.Since(claim.Started_at) < migrationLease {
+		return false, false, nil
+	}
+
+	// Taking over a stale claim only succeeds for one instance, the one
+	// whose update still sees the old started_at.
+	filter := bson.M{"_id": id, "applied_at": nil, "started_at": claim.Started_at}
+	result, err := applied.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"started_at": time.Now()}})
+	if err != nil {
+		return false, false, err
+	}
+	if result.ModifiedCount == 1 {
+		log.Println("migration", id, "was interrupted, running it again")
+		return true, false, nil
+	}
+	return false, false, nil
+}
+
+// runMigration runs a claimed migration and marks it applied, or releases
+// the claim when it fails. The release gets its own context, since the
+// migration's may be what ran out.
+func runMigration(applied *mongo.Collection, m migration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	if err := m.up(ctx); err != nil {
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer releaseCancel()
+		if _, releaseErr := applied.DeleteOne(releaseCtx, bson.M{"_id": m.id, "applied_at": nil}); releaseErr != nil {
+			return fmt.Errorf("migration %s failed: %v; releasing its claim also failed: %v", m.id, err, releaseErr)
+		}
+		return fmt.Errorf("migration %s failed: %w", m.id, err)
+	}
+
+	_, err := applied.UpdateOne(ctx, bson.M{"_id": m.id}, bson.M{"$set": bson.M{"applied_at": time.Now()}})
+	return err
+}
+
+// referenceDocument returns the id of the stored document an embedded copy
+// stands for: the one with the same id, else the one with the same unique
+// name. When neither exists the copy is stored as a new document, after
+// prepare, if given, has converted it.
+func referenceDocument(ctx context.Context, collection *mongo.Collection, copy bson.M, idField string, nameField string, prepare func(context.Context, bson.M) error) (string, error) {
+	id, _ := copy[idField].(string)
+	if id != "" {
+		count, err := collection.CountDocuments(ctx, bson.M{idField: id})
+		if err != nil || count > 0 {
+			return id, err
+		}
+	}
+
+	var existing bson.M
+	err := collection.FindOne(ctx, bson.M{nameField: copy[nameField]}).Decode(&existing)
+	if err == nil {
+		id, _ = existing[idField].(string)
+		return id, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return "", err
+	}
+
+	if prepare != nil {
+		if err := prepare(ctx, copy); err != nil {
+			return "", err
+		}
+	}
+
+	oid := primitive.NewObjectID()
+	copy["_id"] = oid
+	if id == "" {
+		id = oid.Hex()
+		copy[idField] = id
+	}
+	_, err = collection.InsertOne(ctx, copy)
+	return id, err
+}
+
+// referenceMaterials stores each embedded material as needed and returns
+// their ids in order.
+func referenceMaterials(ctx context.Context, materials []bson.M) ([]string, error) {
+	collection := OpenOrCreateDB(Client, "study_material")
+	ids := []string{}
+	for _, material := range materials {
+		id, err := referenceDocument(ctx, collection, material, "material_id", "material_title", nil)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// referenceCourseMaterials replaces each course's embedded course_materials
+// with material_ids.
+func referenceCourseMaterials(ctx context.Context) error {
+	courses := OpenOrCreateDB(Client, "course")
+	cursor, err := courses.Find(ctx, bson.M{"course_materials": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var course struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			Materials []bson.M           `bson:"course_materials"`
+		}
+		if err := cursor.Decode(&course); err != nil {
+			return err
+		}
+
+		ids, err := referenceMaterials(ctx, course.Materials)
+		if err != nil {
+			return err
+		}
+
+		update := bson.M{"$set": bson.M{"material_ids": ids}, "$unset": bson.M{"course_materials": ""}}
+		if _, err := courses.UpdateOne(ctx, bson.M{"_id": course.ID}, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// referencePlanCourses replaces each plan's embedded course list with
+// course_ids. Embedded courses that have to be stored get material_ids the
+// same way as in referenceCourseMaterials.
+func referencePlanCourses(ctx context.Context) error {
+	plans := OpenOrCreateDB(Client, "plan")
+	courses := OpenOrCreateDB(Client, "course")
+	cursor, err := plans.Find(ctx, bson.M{"course": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var plan struct {
+			ID      primitive.ObjectID `bson:"_id"`
+			Courses []bson.M           `bson:"course"`
+		}
+		if err := cursor.Decode(&plan); err != nil {
+			return err
+		}
+
+		ids := []string{}
+		for _, course := range plan.Courses {
+			id, err := referenceDocument(ctx, courses, course, "course_id", "course_name", embedMaterialIds)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		update := bson.M{"$set": bson.M{"course_ids": ids}, "$unset": bson.M{"course": ""}}
+		if _, err := plans.UpdateOne(ctx, bson.M{"_id": plan.ID}, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// embedMaterialIds converts the embedded materials of a course copy that is
+// about to be stored.
+func embedMaterialIds(ctx context.Context, course bson.M) error {
+	materials := []bson.M{}
+	if embedded, ok := course["course_materials"].(primitive.A); ok {
+		for _, item := range embedded {
+			if material, ok := item.(bson.M); ok {
+				materials = append(materials, material)
+			}
+		}
+	}
+
+	ids, err := referenceMaterials(ctx, materials)
+	if err != nil {
+		return err
+	}
+	delete(course, "course_materials")
+	course["material_ids"] = ids
+	return nil
+}
+
+// sumListed adds up the duration stored in durationField of each document
+// listed in ids, counting repeated ids again.
+func sumListed(ctx context.Context, collection *mongo.Collection, idField string, durationField string, ids []string) (int64, error) {
+	var total int64
+	for _, id := range ids {
+		var doc bson.Raw
+		err := collection.FindOne(ctx, bson.M{idField: id}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		duration, _ := doc.Lookup(durationField).AsInt64OK()
+		total += duration
+	}
+	return total, nil
+}
+
+// rollUpDurations replaces the hand-entered course durations with the sum of
+// their materials and gives each plan the sum of its courses.
+func rollUpDurations(ctx context.Context) error {
+	steps := []struct {
+		collection string
+		listField  string
+		from       string
+		idField    string
+		duration   string
+	}{
+		{"course", "material_ids", "study_material", "material_id", "time_duration"},
+		{"plan", "course_ids", "course", "course_id", "total_duration"},
+	}
+
+	for _, step := range steps {
+		collection := OpenOrCreateDB(Client, step.collection)
+		cursor, err := collection.Find(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+
+		for cursor.Next(ctx) {
+			ids := []string{}
+			if list, ok := cursor.Current.Lookup(step.listField).ArrayOK(); ok {
+				values, _ := list.Values()
+				for _, value := range values {
+					if id, ok := value.StringValueOK(); ok {
+						ids = append(ids, id)
+					}
+				}
+			}
+
+			total, err := sumListed(ctx, OpenOrCreateDB(Client, step.from), step.idField, step.duration, ids)
+			if err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			if _, err := collection.UpdateOne(ctx, bson.M{"_id": cursor.Current.Lookup("_id")}, bson.M{"$set": bson.M{"total_duration": total}}); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			cursor.Close(ctx)
+			return err
+		}
+		cursor.Close(ctx)
+	}
+	return nil
+}
+
+// normaliseUserEmails trims and lowercases stored emails and pending emails
+// the way helpers.NormalizeEmail does for new ones. An account whose
+// normalised email already belongs to another account is left as it is and
+// logged, so an operator can merge the two.
+func normaliseUserEmails(ctx context.Context) error {
+	users := OpenOrCreateDB(Client, "user")
+	cursor, err := users.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user struct {
+			ID            primitive.ObjectID `bson:"_id"`
+			Email         *string            `bson:"email"`
+			Pending_email *string            `bson:"pending_email"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		set := bson.M{}
+		for field, value := range map[string]*string{"email": user.Email, "pending_email": user.Pending_email} {
+			if value == nil {
+				continue
+			}
+			if normalised := strings.ToLower(strings.TrimSpace(*value)); normalised != *value {
+				set[field] = normalised
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+
+		_, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set})
+		if mongo.IsDuplicateKeyError(err) {
+			log.Println("not normalising the email of user", user.ID.Hex()+": another account already uses", set)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
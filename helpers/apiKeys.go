@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"Gate/database"
+	"Gate/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// APIKeyPrefix starts every personal API key so it can be told apart from a
+// JWT in the Authorization header.
+const APIKeyPrefix = "gk_"
+
+// lastUsedResolution limits how often last_used_at is written for a busy key.
+const lastUsedResolution = time.Minute
+
+var apiKeyCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "api_key")
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// NewAPIKey returns a fresh key, the hash to store and a short prefix that
+// identifies the key in listings without revealing it.
+func NewAPIKey() (key string, hash string, prefix string, err error) {
+	token, _, err := NewOneTimeToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + token
+	return key, HashOneTimeToken(key), key[:len(APIKeyPrefix)+8], nil
+}
+
+// AuthenticateAPIKey looks up an API key and its owner, refusing revoked and
+// expired keys, and records that the key was used.
+func AuthenticateAPIKey(key string) (*models.Api_Key, *models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var apiKey models.Api_Key
+	err := apiKeyCollection.FindOne(ctx, bson.M{"key_hash": HashOneTimeToken(key)}).Decode(&apiKey)
+	if err != nil {
+		return nil, nil, errors.New("the api key is invalid")
+	}
+
+	now := time.Now()
+	if apiKey.Revoked_at != nil {
+		return nil, nil, errors.New("the api key has been revoked")
+	}
+	if apiKey.Expires_at != nil && apiKey.Expires_at.Before(now) {
+		return nil, nil, errors.New("the api key has expired")
+	}
+
+	var user models.User
+	err = collection.FindOne(ctx, bson.M{"user_id": apiKey.User_id, "deleted_at": nil, "deactivated_at": nil}).Decode(&user)
+	if err != nil {
+		return nil, nil, errors.New("the api key is invalid")
+	}
+
+	filter := bson.M{
+		"api_key_id": apiKey.Api_key_id,
+		"$or": []bson.M{
+			{"last_used_at": nil},
+			{"last_used_at": bson.M{"$lt": now.Add(-lastUsedResolution)}},
+		},
+	}
+	apiKeyCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": now}})
+
+	return &apiKey, &user, nil
+}
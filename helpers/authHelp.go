@@ -6,21 +6,46 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CheckUserType(c *gin.Context, role string) (err error) {
+// CheckPermission checks the permission against the user's role. Requests
+// made with an API key are further limited to the scopes of that key.
+func CheckPermission(c *gin.Context, permission string) (err error) {
 	userType := c.GetString("user_type")
 	err = nil
-	if userType != role {
+	if !HasPermission(userType, permission) {
 		err = errors.New("Unauthorized to access this resource")
+		return err
+	}
+
+	if scopes, ok := c.Get("scopes"); ok && !scopeGrants(scopes.([]string), permission) {
+		err = errors.New("the api key does not have the required scope")
 	}
 	return err
 }
 
+// CheckRoleRank returns an error when role ranks above the caller's own
+// role, so nobody can manage users above them or hand out such a role.
+func CheckRoleRank(c *gin.Context, role string) error {
+	if !RoleCovers(c.GetString("user_type"), role) {
+		return errors.New("the role " + role + " ranks above your own")
+	}
+	return nil
+}
+
+func scopeGrants(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if scope == permission || scope == PermAll {
+			return true
+		}
+	}
+	return false
+}
+
 func MatchUserUID(c *gin.Context, userId string) (err error) {
 	userType := c.GetString("user_type")
 	uid := c.GetString("uid")
 	err = nil
 
-	if userType == "USER" && uid != userId {
+	if !HasPermission(userType, PermUserRead) && uid != userId {
 		err = errors.New("Unauthorized to access this resource")
 		return err
 	}
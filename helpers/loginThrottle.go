@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"Gate/database"
+	"context"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Failed logins are counted per account and per client IP. The first few
+// failures are free, after that each one doubles the wait before the next
+// attempt, and reaching the limit locks the key out for lockoutDuration.
+// Counters start over once failureWindow passes without a failure.
+const (
+	freeLoginAttempts = 3
+	accountLoginLimit = 10
+	ipLoginLimit      = 50
+	baseLoginBackoff  = time.Second
+	maxLoginBackoff   = 15 * time.Minute
+	lockoutDuration   = 15 * time.Minute
+	failureWindow     = time.Hour
+)
+
+type loginAttempt struct {
+	Key             string    `bson:"key"`
+	Failures        int       `bson:"failures"`
+	Last_failure    time.Time `bson:"last_failure"`
+	Next_allowed_at time.Time `bson:"next_allowed_at"`
+}
+
+var loginAttemptCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "login_attempt")
+
+func AccountLoginKey(email string) string {
+	return "email:" + NormalizeEmail(email)
+}
+
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginRetryAfter returns how long the caller must wait before another login
+// attempt is allowed for any of the keys, or zero if it may try now.
+func LoginRetryAfter(keys ...string) time.Duration {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := loginAttemptCollection.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return 0
+	}
+	defer cursor.Close(ctx)
+
+	var wait time.Duration
+	for cursor.Next(ctx) {
+		var attempt loginAttempt
+		if cursor.Decode(&attempt) != nil {
+			continue
+		}
+		if d := time.Until(attempt.Next_allowed_at); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+func loginBackoff(failures int, limit int) time.Duration {
+	if failures >= limit {
+		return lockoutDuration
+	}
+	if failures <= freeLoginAttempts {
+		return 0
+	}
+	backoff := baseLoginBackoff * time.Duration(math.Pow(2, float64(failures-freeLoginAttempts-1)))
+	if backoff > maxLoginBackoff {
+		backoff = maxLoginBackoff
+	}
+	return backoff
+}
+
+func recordLoginFailure(ctx context.Context, key string, limit int) error {
+	now := time.Now()
+
+	stale := bson.M{"key": key, "last_failure": bson.M{"$lt": now.Add(-failureWindow)}}
+	if _, err := loginAttemptCollection.UpdateOne(ctx, stale, bson.M{"$set": bson.M{"failures": 0}}); err != nil {
+		return err
+	}
+
+	var attempt loginAttempt
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure": now},
+	}
+	err := loginAttemptCollection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, opt).Decode(&attempt)
+	if err != nil {
+		return err
+	}
+
+	nextAllowed := now.Add(loginBackoff(attempt.Failures, limit))
+	_, err = loginAttemptCollection.UpdateOne(ctx, bson.M{"key": key}, bson.M{"$set": bson.M{"next_allowed_at": nextAllowed}})
+	return err
+}
+
+func RecordLoginFailure(email string, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := recordLoginFailure(ctx, AccountLoginKey(email), accountLoginLimit); err != nil {
+		return err
+	}
+	return recordLoginFailure(ctx, IPLoginKey(ip), ipLoginLimit)
+}
+
+// ResetLoginFailures clears the failure count of an account, after a
+// successful login or when an admin unlocks it.
+func ResetLoginFailures(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := loginAttemptCollection.DeleteOne(ctx, bson.M{"key": AccountLoginKey(email)})
+	return err
+}
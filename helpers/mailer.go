@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional mail such as password reset links. The
+// implementation is picked from the MAILER environment variable and can be
+// replaced with SetMailer.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(mail Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + mail.To + "\r\n" +
+		"Subject: " + mail.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + mail.Body + "\r\n"
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{mail.To}, []byte(msg))
+}
+
+// FileMailer writes every mail to its own file in Dir instead of sending it.
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(mail Mail) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), strings.ReplaceAll(mail.To, "/", "_"))
+	content := "To: " + mail.To + "\nSubject: " + mail.Subject + "\n\n" + mail.Body + "\n"
+
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o600)
+}
+
+// MemoryMailer keeps sent mail in memory so it can be inspected.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	Sent []Mail
+}
+
+func (m *MemoryMailer) Send(mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Sent = append(m.Sent, mail)
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.Sent...)
+}
+
+var mailer Mailer
+
+// ConfigureMailer picks the mailer from MAILER. "file" and "memory" keep
+// live reset and invite links on disk or in memory and are meant for
+// development, so they have to be chosen explicitly; startup fails when
+// MAILER is unset rather than falling back to one of them.
+func ConfigureMailer() error {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		if os.Getenv("SMTP_HOST") == "" || os.Getenv("MAIL_FROM") == "" {
+			return errors.New("SMTP_HOST and MAIL_FROM are required when MAILER is smtp")
+		}
+		// Links in real mail must not point at the localhost defaults.
+		if os.Getenv("APP_BASE_URL") == "" || os.Getenv("RESET_PASSWORD_URL") == "" {
+			return errors.New("APP_BASE_URL and RESET_PASSWORD_URL are required when MAILER is smtp")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		mailer = &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	case "memory":
+		mailer = &MemoryMailer{}
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		mailer = &FileMailer{Dir: dir}
+	case "":
+		return errors.New("MAILER is not set; use smtp, or file or memory for development")
+	default:
+		return errors.New("unknown MAILER " + os.Getenv("MAILER"))
+	}
+	return nil
+}
+
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+func SendMail(mail Mail) error {
+	if mailer == nil {
+		err := errors.New("no mailer is configured")
+		log.Println("error occured while sending mail to", mail.To, err)
+		return err
+	}
+	err := mailer.Send(mail)
+	if err != nil {
+		log.Println("error occured while sending mail to", mail.To, err)
+	}
+	return err
+}
+
+// AppURL builds an absolute link to the given path for use in emails.
+func AppURL(path string) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8000"
+		}
+		base = "http://localhost:" + port
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// ResetPasswordURL links to the page that asks for a new password with a
+// reset or invite token. The page is part of the web app rather than this
+// API, so its address is RESET_PASSWORD_URL; it posts the token with the new
+// password to users/password/reset. Development mailers fall back to that
+// route itself.
+func ResetPasswordURL(token string) string {
+	page := os.Getenv("RESET_PASSWORD_URL")
+	if page == "" {
+		page = AppURL("users/password/reset")
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	return page + separator + "token=" + url.QueryEscape(token)
+}
+
+// NormalizeEmail trims and lowercases an address. Every path that stores or
+// looks up a user's email goes through it, so the unique index on email
+// treats addresses that differ only in case as the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,300 @@
+package helpers
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// OpenID Connect login is configured with OIDC_ISSUER, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET (optional for public clients), OIDC_REDIRECT_URL and
+// OIDC_SCOPES. The provider's endpoints and keys come from its discovery
+// document, so any issuer URL works, including a local mock in tests.
+
+const oidcDiscoveryTTL = time.Hour
+
+type OIDCClaims struct {
+	Issuer         string       `json:"iss"`
+	Subject        string       `json:"sub"`
+	Audience       oidcAudience `json:"aud"`
+	ExpiresAt      int64        `json:"exp"`
+	Email          string       `json:"email"`
+	Email_verified bool         `json:"email_verified"`
+	Given_name     string       `json:"given_name"`
+	Family_name    string       `json:"family_name"`
+	Name           string       `json:"name"`
+	Nonce          string       `json:"nonce"`
+}
+
+func (c *OIDCClaims) Valid() error {
+	if c.ExpiresAt == 0 || time.Now().Unix() > c.ExpiresAt {
+		return errors.New("the id token is expired")
+	}
+	return nil
+}
+
+// oidcAudience accepts both forms of the "aud" claim: a single string or an
+// array of strings. jwt.StandardClaims only handles the first.
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a oidcAudience) contains(clientId string) bool {
+	for _, aud := range a {
+		if aud == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+type oidcProvider struct {
+	Issuer                 string `json:"issuer"`
+	Authorization_endpoint string `json:"authorization_endpoint"`
+	Token_endpoint         string `json:"token_endpoint"`
+	Jwks_uri               string `json:"jwks_uri"`
+}
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var oidcCache = struct {
+	sync.Mutex
+	provider  *oidcProvider
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}{}
+
+func SetOIDCHTTPClient(client *http.Client) {
+	oidcHTTPClient = client
+}
+
+func OIDCEnabled() bool {
+	return os.Getenv("OIDC_ISSUER") != "" && os.Getenv("OIDC_CLIENT_ID") != ""
+}
+
+func oidcIssuer() string {
+	return strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/")
+}
+
+func oidcRedirectURL() string {
+	if redirect := os.Getenv("OIDC_REDIRECT_URL"); redirect != "" {
+		return redirect
+	}
+	return AppURL("auth/oidc/callback")
+}
+
+func getJSON(endpoint string, target interface{}) error {
+	resp, err := oidcHTTPClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func discoverOIDCProvider() (*oidcProvider, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	if oidcCache.provider != nil && time.Since(oidcCache.fetchedAt) < oidcDiscoveryTTL {
+		return oidcCache.provider, nil
+	}
+
+	var provider oidcProvider
+	if err := getJSON(oidcIssuer()+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(provider.Issuer, "/") != oidcIssuer() {
+		return nil, errors.New("the discovery document is for a different issuer")
+	}
+
+	oidcCache.provider = &provider
+	oidcCache.keys = nil
+	oidcCache.fetchedAt = time.Now()
+	return &provider, nil
+}
+
+func oidcKey(kid string) (*rsa.PublicKey, error) {
+	provider, err := discoverOIDCProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	if key, ok := oidcCache.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Unknown kid: the provider may have rotated, so fetch its keys again.
+	var jwks struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := getJSON(provider.Jwks_uri, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	oidcCache.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, errors.New("the id token was signed with an unknown key")
+	}
+	return key, nil
+}
+
+// NewPKCE returns a PKCE code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (verifier string, challenge string, err error) {
+	token, _, err := NewOneTimeToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier = base64.RawURLEncoding.EncodeToString([]byte(token))
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func OIDCAuthURL(state string, nonce string, codeChallenge string) (string, error) {
+	provider, err := discoverOIDCProvider()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := os.Getenv("OIDC_SCOPES")
+	if scopes == "" {
+		scopes = "openid email profile"
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", os.Getenv("OIDC_CLIENT_ID"))
+	query.Set("redirect_uri", oidcRedirectURL())
+	query.Set("scope", scopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.Authorization_endpoint, "?") {
+		separator = "&"
+	}
+	return provider.Authorization_endpoint + separator + query.Encode(), nil
+}
+
+// OIDCExchangeCode trades an authorization code for the provider's tokens
+// and returns the raw ID token.
+func OIDCExchangeCode(code string, codeVerifier string) (string, error) {
+	provider, err := discoverOIDCProvider()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", oidcRedirectURL())
+	form.Set("client_id", os.Getenv("OIDC_CLIENT_ID"))
+	form.Set("code_verifier", codeVerifier)
+	if secret := os.Getenv("OIDC_CLIENT_SECRET"); secret != "" {
+		form.Set("client_secret", secret)
+	}
+
+	resp, err := oidcHTTPClient.PostForm(provider.Token_endpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Id_token          string `json:"id_token"`
+		Error             string `json:"error"`
+		Error_description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", body.Error, body.Error_description)
+	}
+	if body.Id_token == "" {
+		return "", errors.New("the provider did not return an id token")
+	}
+	return body.Id_token, nil
+}
+
+// VerifyOIDCIDToken checks the ID token signature against the provider's
+// keys and its issuer, audience, expiry and nonce.
+func VerifyOIDCIDToken(rawIDToken string, nonce string) (*OIDCClaims, error) {
+	token, err := jwt.ParseWithClaims(rawIDToken, &OIDCClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return oidcKey(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*OIDCClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("the id token is invalid")
+	}
+	if strings.TrimRight(claims.Issuer, "/") != oidcIssuer() {
+		return nil, errors.New("the id token has the wrong issuer")
+	}
+	if !claims.Audience.contains(os.Getenv("OIDC_CLIENT_ID")) {
+		return nil, errors.New("the id token has the wrong audience")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("the id token nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("the id token has no subject")
+	}
+	return claims, nil
+}
@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NewOneTimeToken returns a random token to hand to the user and the hash of
+// it to store. Only the hash is ever persisted.
+func NewOneTimeToken() (token string, hash string, err error) {
+	bytes := make([]byte, 32)
+	if _, err = rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(bytes)
+	return token, HashOneTimeToken(token), nil
+}
+
+func HashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"Gate/database"
+	"Gate/models"
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PermAccountSelf   = "account:self"
+	PermUserRead      = "user:read"
+	PermUserWrite     = "user:write"
+	PermRoleWrite     = "role:write"
+	PermMaterialRead  = "material:read"
+	PermMaterialWrite = "material:write"
+	PermCourseRead    = "course:read"
+	PermCourseWrite   = "course:write"
+	PermPlanRead      = "plan:read"
+	PermPlanWrite     = "plan:write"
+	PermProgressRead  = "progress:read"
+
+	// PermAll grants every permission, including ones added later.
+	PermAll = "*"
+)
+
+var Permissions = []string{
+	PermAccountSelf,
+	PermUserRead,
+	PermUserWrite,
+	PermRoleWrite,
+	PermMaterialRead,
+	PermMaterialWrite,
+	PermCourseRead,
+	PermCourseWrite,
+	PermPlanRead,
+	PermPlanWrite,
+	PermProgressRead,
+	PermAll,
+}
+
+var studentPermissions = []string{PermAccountSelf, PermMaterialRead, PermCourseRead, PermPlanRead}
+
+// defaultRoles are created at startup. Built-in roles always keep at least
+// these permissions; extra ones granted through the API are preserved.
+var defaultRoles = map[string][]string{
+	"USER":        studentPermissions,
+	"EDITOR":      append([]string{PermMaterialWrite, PermCourseWrite, PermPlanWrite}, studentPermissions...),
+	"MENTOR":      append([]string{PermProgressRead}, studentPermissions...),
+	"ADMIN":       append([]string{PermUserRead, PermUserWrite, PermMaterialWrite, PermCourseWrite, PermPlanWrite, PermProgressRead}, studentPermissions...),
+	"SUPER_ADMIN": {PermAll},
+}
+
+var roleCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "role")
+
+const roleCacheTTL = 30 * time.Second
+
+var roleCache = struct {
+	sync.RWMutex
+	roles    map[string][]string
+	loadedAt time.Time
+}{}
+
+func EnsureDefaultRoles() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	upsert := true
+	opt := options.UpdateOptions{
+		Upsert: &upsert,
+	}
+
+	for name, permissions := range defaultRoles {
+		update := bson.M{
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": now, "updated_at": now},
+			"$addToSet":    bson.M{"permissions": bson.M{"$each": permissions}},
+		}
+		if _, err := roleCollection.UpdateOne(ctx, bson.M{"name": name}, update, &opt); err != nil {
+			return err
+		}
+	}
+
+	InvalidateRoleCache()
+	return nil
+}
+
+func IsKnownPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func InvalidateRoleCache() {
+	roleCache.Lock()
+	roleCache.roles = nil
+	roleCache.Unlock()
+}
+
+func loadRoles() map[string][]string {
+	roleCache.RLock()
+	roles, loadedAt := roleCache.roles, roleCache.loadedAt
+	roleCache.RUnlock()
+
+	if roles != nil && time.Since(loadedAt) < roleCacheTTL {
+		return roles
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := roleCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return roles
+	}
+	defer cursor.Close(ctx)
+
+	roles = map[string][]string{}
+	for cursor.Next(ctx) {
+		var role models.Role
+		if cursor.Decode(&role) == nil && role.Name != nil {
+			roles[*role.Name] = role.Permissions
+		}
+	}
+
+	roleCache.Lock()
+	roleCache.roles = roles
+	roleCache.loadedAt = time.Now()
+	roleCache.Unlock()
+
+	return roles
+}
+
+func RoleExists(role string) bool {
+	_, ok := loadRoles()[role]
+	return ok
+}
+
+func RolePermissions(role string) []string {
+	return loadRoles()[role]
+}
+
+// RoleCovers reports whether role holds every permission of other, that is
+// whether a user with role ranks at least as high as a user with other.
+func RoleCovers(role string, other string) bool {
+	if HasPermission(role, PermAll) {
+		return true
+	}
+	for _, permission := range RolePermissions(other) {
+		if !HasPermission(role, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+func HasPermission(role string, permission string) bool {
+	for _, p := range RolePermissions(role) {
+		if p == permission || p == PermAll {
+			return true
+		}
+	}
+	return false
+}
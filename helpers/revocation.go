@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"Gate/database"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type revokedToken struct {
+	Jti        string    `bson:"jti"`
+	Expires_at time.Time `bson:"expires_at"`
+	Revoked_at time.Time `bson:"revoked_at"`
+}
+
+var revokedCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "revoked_token")
+
+// RevokeToken records the token ID (jti) of a token so that it is refused
+// until it expires.
+func RevokeToken(jti string, expiresAt int64) error {
+	if jti == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	revokedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	record := revokedToken{
+		Jti:        jti,
+		Expires_at: time.Unix(expiresAt, 0),
+		Revoked_at: revokedAt,
+	}
+
+	upsert := true
+	opt := options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	_, err := revokedCollection.UpdateOne(ctx, bson.M{"jti": jti}, bson.M{"$set": record}, &opt)
+	return err
+}
+
+// IsTokenRevoked reports whether the token ID has been revoked. A lookup error
+// is treated as revoked so that an unavailable store fails closed.
+func IsTokenRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := revokedCollection.CountDocuments(ctx, bson.M{"jti": jti})
+	if err != nil {
+		return true
+	}
+	return count > 0
+}
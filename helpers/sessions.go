@@ -0,0 +1,203 @@
+package helpers
+
+import (
+	"Gate/database"
+	"Gate/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lastSeenResolution limits how often an active session's last_seen_at is
+// written.
+const lastSeenResolution = time.Minute
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// exchanged is presented again. The session is revoked when this happens.
+var ErrRefreshTokenReused = errors.New("refresh token has already been used, please login again")
+
+var sessionCollection *mongo.Collection = database.OpenOrCreateDB(database.Client, "session")
+
+// issueSessionTokens signs a token pair for the session and returns the
+// fields that must be stored on it.
+func issueSessionTokens(user models.User, sessionId string) (token string, refreshToken string, fields bson.M, err error) {
+	token, refreshToken, err = GenerateTokens(user, sessionId)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	access, msg := ValidateTokens(token)
+	if msg != "" {
+		return "", "", nil, errors.New(msg)
+	}
+	refresh, msg := ValidateTokens(refreshToken)
+	if msg != "" {
+		return "", "", nil, errors.New(msg)
+	}
+
+	fields = bson.M{
+		"refresh_token_hash": HashOneTimeToken(refreshToken),
+		"access_jti":         access.Id,
+		"access_expires_at":  time.Unix(access.ExpiresAt, 0),
+		"refresh_jti":        refresh.Id,
+		"refresh_expires_at": time.Unix(refresh.ExpiresAt, 0),
+	}
+	return token, refreshToken, fields, nil
+}
+
+// CreateSession starts a new session for a user who has just logged in and
+// returns its token pair.
+func CreateSession(user models.User, userAgent string, ip string) (token string, refreshToken string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	id := primitive.NewObjectID()
+	token, refreshToken, fields, err := issueSessionTokens(user, id.Hex())
+	if err != nil {
+		return "", "", err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	session := bson.M{
+		"_id":          id,
+		"session_id":   id.Hex(),
+		"user_id":      user.User_id,
+		"user_agent":   userAgent,
+		"ip":           ip,
+		"created_at":   now,
+		"last_seen_at": now,
+	}
+	for k, v := range fields {
+		session[k] = v
+	}
+
+	if _, err := sessionCollection.InsertOne(ctx, session); err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// RotateSession exchanges the session's current refresh token for a new pair.
+// Matching on the stored hash makes each refresh token usable once; a token
+// that no longer matches revokes the session and returns
+// ErrRefreshTokenReused.
+func RotateSession(user models.User, sessionId string, oldRefreshToken string, ip string) (token string, refreshToken string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	token, refreshToken, fields, err := issueSessionTokens(user, sessionId)
+	if err != nil {
+		return "", "", err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	fields["last_seen_at"] = now
+	fields["ip"] = ip
+
+	var previous models.Session
+	filter := bson.M{
+		"session_id":         sessionId,
+		"user_id":            user.User_id,
+		"refresh_token_hash": HashOneTimeToken(oldRefreshToken),
+		"revoked_at":         nil,
+	}
+	err = sessionCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": fields}).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		if err := RevokeSession(sessionId); err != nil && err != mongo.ErrNoDocuments {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// The access token issued with the old refresh token is replaced too.
+	if err := RevokeToken(previous.Access_jti, previous.Access_expires_at.Unix()); err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// RevokeSession ends a session and revokes the tokens issued for it.
+func RevokeSession(sessionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	var session models.Session
+	filter := bson.M{"session_id": sessionId}
+	update := bson.M{"$set": bson.M{"revoked_at": now}}
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	if err := sessionCollection.FindOneAndUpdate(ctx, filter, update, opt).Decode(&session); err != nil {
+		return err
+	}
+
+	if err := RevokeToken(session.Access_jti, session.Access_expires_at.Unix()); err != nil {
+		return err
+	}
+	return RevokeToken(session.Refresh_jti, session.Refresh_expires_at.Unix())
+}
+
+// RevokeUserSessions ends every active session of a user, logging them out
+// on all devices.
+func RevokeUserSessions(userId string) error {
+	sessions, err := ListSessions(userId)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := RevokeSession(session.Session_id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the user's sessions that are neither revoked nor past
+// their refresh token expiry, most recently used first.
+func ListSessions(userId string) ([]models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":            userId,
+		"revoked_at":         nil,
+		"refresh_expires_at": bson.M{"$gt": time.Now()},
+	}
+	cursor, err := sessionCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"last_seen_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	for cursor.Next(ctx) {
+		var session models.Session
+		cursor.Decode(&session)
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// TouchSession records activity on a session, at most once per
+// lastSeenResolution.
+func TouchSession(sessionId string) {
+	if sessionId == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"session_id": sessionId, "last_seen_at": bson.M{"$lt": now.Add(-lastSeenResolution)}}
+	sessionCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_seen_at": now}})
+}
@@ -0,0 +1,297 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Tokens are signed with RS256 using RSA private keys read from the PEM files
+// in JWT_KEY_DIR. The file name without extension is the key ID (kid). A new
+// key is published in the JWKS as soon as it is picked up, but only signs
+// tokens once it has been there for JWT_KEY_PUBLISH_DELAY, so relying
+// parties that cache the JWKS know it before they see a token signed with
+// it. From then on it is the current key. Older keys keep verifying tokens
+// for JWT_KEY_GRACE after the key that replaced them started signing, so a
+// rotation does not log anyone out. Dropping a new file into the directory is
+// enough to rotate; it is picked up within keyReloadInterval.
+
+const keyReloadInterval = time.Minute
+
+// JWKSMaxAge is how long clients may cache the JWKS.
+const JWKSMaxAge = 5 * time.Minute
+
+type signingKey struct {
+	Kid        string
+	Private    *rsa.PrivateKey
+	Added_at   time.Time
+	Signs_at   time.Time
+	Retires_at time.Time
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var keyring = struct {
+	sync.RWMutex
+	current  *signingKey
+	keys     map[string]*signingKey
+	loadedAt time.Time
+}{}
+
+func keyDir() string {
+	dir := os.Getenv("JWT_KEY_DIR")
+	if dir == "" {
+		dir = "keys"
+	}
+	return dir
+}
+
+func keyGrace() time.Duration {
+	grace, err := time.ParseDuration(os.Getenv("JWT_KEY_GRACE"))
+	if err != nil || grace <= 0 {
+		// As long as a refresh token lives.
+		grace = 168 * time.Hour
+	}
+	return grace
+}
+
+// keyPublishDelay is how long a new key is only published before it signs.
+// It defaults to the JWKS cache lifetime plus the time it takes every
+// instance to pick the key up.
+func keyPublishDelay() time.Duration {
+	delay, err := time.ParseDuration(os.Getenv("JWT_KEY_PUBLISH_DELAY"))
+	if err != nil || delay < 0 {
+		delay = JWKSMaxAge + keyReloadInterval
+	}
+	return delay
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("only RSA keys are supported")
+	}
+	return key, nil
+}
+
+func generateKeyFile(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z")+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return err
+	}
+
+	log.Println("generated signing key", name)
+	return nil
+}
+
+func readKeys(dir string) ([]*signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*signingKey
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		private, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys = append(keys, &signingKey{
+			Kid:      strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			Private:  private,
+			Added_at: info.ModTime(),
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Added_at.Before(keys[j].Added_at) })
+	return keys, nil
+}
+
+func loadKeys() error {
+	dir := keyDir()
+	keys, err := readKeys(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		if err := generateKeyFile(dir); err != nil {
+			return err
+		}
+		if keys, err = readKeys(dir); err != nil {
+			return err
+		}
+	}
+
+	// The first key signs right away, no one can have cached a JWKS
+	// without it.
+	grace, delay := keyGrace(), keyPublishDelay()
+	for i, key := range keys {
+		key.Signs_at = key.Added_at.Add(delay)
+		if i == 0 {
+			key.Signs_at = key.Added_at
+		}
+	}
+
+	now := time.Now()
+	current := keys[0]
+	for _, key := range keys {
+		if !now.Before(key.Signs_at) {
+			current = key
+		}
+	}
+
+	active := map[string]*signingKey{}
+	for i, key := range keys {
+		if i+1 < len(keys) {
+			key.Retires_at = keys[i+1].Signs_at.Add(grace)
+			if now.After(key.Retires_at) && key != current {
+				continue
+			}
+		}
+		active[key.Kid] = key
+	}
+
+	keyring.Lock()
+	keyring.current = current
+	keyring.keys = active
+	keyring.loadedAt = now
+	keyring.Unlock()
+
+	return nil
+}
+
+func activeKeys() (*signingKey, map[string]*signingKey, error) {
+	keyring.RLock()
+	current, keys, loadedAt := keyring.current, keyring.keys, keyring.loadedAt
+	keyring.RUnlock()
+
+	if current != nil && time.Since(loadedAt) < keyReloadInterval {
+		return current, keys, nil
+	}
+
+	if err := loadKeys(); err != nil {
+		if current != nil {
+			log.Println("error occured while reloading signing keys", err)
+			return current, keys, nil
+		}
+		return nil, nil, err
+	}
+
+	keyring.RLock()
+	defer keyring.RUnlock()
+	return keyring.current, keyring.keys, nil
+}
+
+// LoadSigningKeys reads the key directory, creating a first key if it is
+// empty. It is called at startup so a broken key directory fails fast.
+func LoadSigningKeys() error {
+	return loadKeys()
+}
+
+func signClaims(claims jwt.Claims) (string, error) {
+	current, _, err := activeKeys()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = current.Kid
+	return token.SignedString(current.Private)
+}
+
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	_, keys, err := activeKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, errors.New("the token was signed with an unknown or retired key")
+	}
+	return &key.Private.PublicKey, nil
+}
+
+// JWKS returns the public halves of every key that tokens may currently be
+// signed with, in RFC 7517 form.
+func JWKS() ([]JSONWebKey, error) {
+	_, keys, err := activeKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks []JSONWebKey
+	for _, key := range keys {
+		public := key.Private.PublicKey
+		jwks = append(jwks, JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: key.Kid,
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks, nil
+}
@@ -2,56 +2,71 @@ package helpers
 
 import (
 	"Gate/database"
+	"Gate/models"
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
-	Email      string
-	First_Name string
-	Last_Name  string
-	Uid        string
-	User_type  string
+	Email          string
+	First_Name     string
+	Last_Name      string
+	Uid            string
+	User_type      string
+	Token_type     string
+	Email_verified bool
+	Session_id     string
 	jwt.StandardClaims
 }
 
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+	MFAToken     = "mfa_pending"
+)
+
 var collection *mongo.Collection = database.OpenOrCreateDB(database.Client, "user")
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
 
-func GenerateTokens(email string, firstName string, lastName string, userType string, uid string) (signedToken string, signedRefreshToken string, err error) {
+func GenerateTokens(user models.User, sessionId string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
-		Email:      email,
-		First_Name: firstName,
-		Last_Name:  lastName,
-		Uid:        uid,
-		User_type:  userType,
+		Email:          *user.Email,
+		First_Name:     *user.First_Name,
+		Last_Name:      *user.Last_Name,
+		Uid:            user.User_id,
+		User_type:      *user.User_type,
+		Token_type:     AccessToken,
+		Email_verified: user.Email_verified,
+		Session_id:     sessionId,
 		StandardClaims: jwt.StandardClaims{
+			Id:        primitive.NewObjectID().Hex(),
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
 		},
 	}
 
 	refreshClaims := &SignedDetails{
+		Uid:        user.User_id,
+		Token_type: RefreshToken,
+		Session_id: sessionId,
 		StandardClaims: jwt.StandardClaims{
+			Id:        primitive.NewObjectID().Hex(),
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	token, err := signClaims(claims)
 	if err != nil {
 		log.Panic(err)
 		return
 	}
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	refreshToken, err := signClaims(refreshClaims)
 	if err != nil {
 		log.Panic(err)
 		return
@@ -60,37 +75,26 @@ func GenerateTokens(email string, firstName string, lastName string, userType st
 	return token, refreshToken, err
 }
 
-func UpdateTokens(signedToken string, signedRefreshToken string, userId string) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	var updatedObj primitive.D
-
-	updatedObj = append(updatedObj, bson.E{"token", signedToken})
-	updatedObj = append(updatedObj, bson.E{"refresh_token", signedRefreshToken})
-	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updatedObj = append(updatedObj, bson.E{"updated_at", updatedAt})
-
-	upsert := true
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+// GenerateMFAToken returns a short-lived token that proves the password was
+// checked. It can only be exchanged for real tokens at users/2fa/verify.
+func GenerateMFAToken(user models.User) (string, error) {
+	claims := &SignedDetails{
+		Uid:        user.User_id,
+		Token_type: MFAToken,
+		StandardClaims: jwt.StandardClaims{
+			Id:        primitive.NewObjectID().Hex(),
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(5)).Unix(),
+		},
 	}
 
-	_, err := collection.UpdateOne(ctx, filter, bson.D{{"$set", updatedObj}}, &opt)
-	defer cancel()
-
-	if err != nil {
-		log.Panic(err)
-		return
-	}
+	return signClaims(claims)
 }
 
 func ValidateTokens(signedToken string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
+		verificationKey,
 	)
 
 	if err != nil {
@@ -100,17 +104,29 @@ func ValidateTokens(signedToken string) (claims *SignedDetails, msg string) {
 
 	claims, ok := token.Claims.(*SignedDetails)
 
-	if !ok {
+	if !ok || !token.Valid {
 		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
 		return
 	}
 
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		msg = fmt.Sprintf("token is expired")
-		msg = err.Error()
 		return
 	}
 
 	return claims, msg
 }
+
+// IsEmailVerified reports whether the user has verified their email address.
+// Accounts created before verification existed have no email_verified field
+// and count as verified.
+func IsEmailVerified(userId string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := collection.CountDocuments(ctx, bson.M{"user_id": userId, "email_verified": bson.M{"$ne": false}})
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	controller "Gate/controllers"
+	"Gate/database"
+	"Gate/helpers"
 	routes "Gate/routes"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -18,11 +22,49 @@ func main() {
 	if port == "" {
 		port = "8000"
 	}
+	if err := database.EnsureIndexes(database.Client); err != nil {
+		log.Fatal(err)
+	}
+	if err := database.RunMigrations(database.Client); err != nil {
+		log.Fatal(err)
+	}
+	if err := helpers.ConfigureMailer(); err != nil {
+		log.Fatal(err)
+	}
+	if err := helpers.LoadSigningKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := helpers.EnsureDefaultRoles(); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.BootstrapAdmin(); err != nil {
+		log.Fatal(err)
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger())
 
+	// Client IPs are used for login throttling, so X-Forwarded-For is only
+	// believed when the request comes from a proxy listed in TRUSTED_PROXIES.
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal(err)
+	}
+
 	routes.AuthJWTroutes(router)
 	routes.UserRoutes(router)
 
 	router.Run(":" + port)
 }
+
+// trustedProxies reads the comma separated TRUSTED_PROXIES addresses or
+// CIDR ranges. Without it no proxy is trusted and the client IP is always
+// the address of the connection.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -4,23 +4,88 @@ import (
 	"Gate/helpers"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const authRealm = "gate"
+
+// unverifiedRoutes are the only routes an account with an unverified email
+// address may call.
+var unverifiedRoutes = map[string]bool{
+	"/users/:user_id":      true,
+	"/users/me":            true,
+	"/users/logout":        true,
+	"/users/verify/resend": true,
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header. The
+// legacy "token" header is still accepted during the deprecation window, and
+// responses to it carry a Deprecation header so clients can notice.
+func bearerToken(c *gin.Context) string {
+	header := c.Request.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	if token := c.Request.Header.Get("token"); token != "" {
+		c.Header("Deprecation", "true")
+		c.Header("Link", `<https://datatracker.ietf.org/doc/html/rfc6750>; rel="deprecation"`)
+		return token
+	}
+	return ""
+}
+
+// abortUnauthorized answers 401 with an RFC 6750 challenge. An empty
+// errorCode means no credentials were sent at all.
+func abortUnauthorized(c *gin.Context, errorCode string, description string) {
+	challenge := fmt.Sprintf(`Bearer realm="%s"`, authRealm)
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, errorCode, description)
+	}
+	c.Header("WWW-Authenticate", challenge)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": description})
+	c.Abort()
+}
+
+func abortForbidden(c *gin.Context, description string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s", error="insufficient_scope", error_description="%s"`, authRealm, description))
+	c.JSON(http.StatusForbidden, gin.H{"error": description})
+	c.Abort()
+}
+
 func Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientToken := c.Request.Header.Get("token")
+		clientToken := bearerToken(c)
 		if clientToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
-			c.Abort()
+			abortUnauthorized(c, "", fmt.Sprintf("No Authorization header provided"))
+			return
+		}
+
+		if helpers.IsAPIKey(clientToken) {
+			authenticateAPIKey(c, clientToken)
 			return
 		}
 
 		claims, err := helpers.ValidateTokens(clientToken)
 		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
-			c.Abort()
+			abortUnauthorized(c, "invalid_token", err)
+			return
+		}
+
+		if claims.Token_type != helpers.AccessToken {
+			abortUnauthorized(c, "invalid_token", "the token is not an access token")
+			return
+		}
+
+		if helpers.IsTokenRevoked(claims.Id) {
+			abortUnauthorized(c, "invalid_token", "the token has been revoked")
+			return
+		}
+
+		if !claims.Email_verified && !unverifiedRoutes[c.FullPath()] && !helpers.IsEmailVerified(claims.Uid) {
+			abortForbidden(c, "email address is not verified")
 			return
 		}
 
@@ -29,6 +94,33 @@ func Authenticate() gin.HandlerFunc {
 		c.Set("last_name", claims.Last_Name)
 		c.Set("uid", claims.Uid)
 		c.Set("user_type", claims.User_type)
+		c.Set("jti", claims.Id)
+		c.Set("token_expires_at", claims.ExpiresAt)
+		c.Set("sid", claims.Session_id)
+
+		helpers.TouchSession(claims.Session_id)
 		c.Next()
 	}
 }
+
+func authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, user, err := helpers.AuthenticateAPIKey(key)
+	if err != nil {
+		abortUnauthorized(c, "invalid_token", err.Error())
+		return
+	}
+
+	if !user.Email_verified && !unverifiedRoutes[c.FullPath()] && !helpers.IsEmailVerified(user.User_id) {
+		abortForbidden(c, "email address is not verified")
+		return
+	}
+
+	c.Set("email", *user.Email)
+	c.Set("first_name", *user.First_Name)
+	c.Set("last_name", *user.Last_Name)
+	c.Set("uid", user.User_id)
+	c.Set("user_type", *user.User_type)
+	c.Set("api_key_id", apiKey.Api_key_id)
+	c.Set("scopes", apiKey.Scopes)
+	c.Next()
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"Gate/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission aborts the request unless the authenticated user's role
+// grants the permission. It must run after Authenticate.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helpers.CheckPermission(c, permission); err != nil {
+			abortForbidden(c, err.Error())
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession aborts requests made with an API key. Routes that change
+// credentials or the identity of the account use it, so a leaked key cannot
+// take the account over, whatever its scopes. It must run after
+// Authenticate.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_id"); ok {
+			abortForbidden(c, "this action requires logging in, api keys cannot be used")
+			return
+		}
+		c.Next()
+	}
+}
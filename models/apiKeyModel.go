@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Api_Key struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=3,max=64"`
+	Scopes       []string           `json:"scopes" validate:"required,min=1"`
+	Prefix       string             `json:"prefix"`
+	Key_hash     string             `json:"-"`
+	User_id      string             `json:"user_id"`
+	Expires_at   *time.Time         `json:"expires_at"`
+	Last_used_at *time.Time         `json:"last_used_at"`
+	Revoked_at   *time.Time         `json:"revoked_at"`
+	Api_key_id   string             `json:"api_key_id"`
+	Created_at   time.Time          `json:"created_at"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar_Event is an all-day event in a calendar feed.
+type Calendar_Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// CalendarEvents turns the enrollment's schedule of the expanded plan into
+// one event per study day, marking the materials the student has completed.
+func (e Enrollment) CalendarEvents(plan Study_Plan, completions map[string]bool) []Calendar_Event {
+	planName := "Study plan"
+	if plan.Plan_Name != nil {
+		planName = *plan.Plan_Name
+	}
+
+	events := []Calendar_Event{}
+	for _, day := range e.Schedule(plan).Days {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+
+		lines := []string{}
+		for _, item := range day.Items {
+			line := "- "
+			if completions[item.Material_id] {
+				line = "- [done] "
+			}
+			if item.Material_title != nil {
+				line += *item.Material_title
+			} else {
+				line += item.Material_id
+			}
+			if item.Parts > 1 {
+				line += fmt.Sprintf(" (part %d of %d)", item.Part, item.Parts)
+			}
+			line += fmt.Sprintf(", %d min", item.Minutes)
+			if item.Material_url != nil && *item.Material_url != "" {
+				line += "\n  " + *item.Material_url
+			}
+			lines = append(lines, line)
+		}
+
+		events = append(events, Calendar_Event{
+			UID:         fmt.Sprintf("%s-day-%d@gate", e.Enrollment_id, day.Day),
+			Date:        date,
+			Summary:     fmt.Sprintf("%s: day %d (%d min)", planName, day.Day, day.Minutes),
+			Description: strings.Join(lines, "\n"),
+		})
+	}
+	return events
+}
+
+// icalEscape escapes a TEXT value as RFC 5545 section 3.3.11 requires. Line
+// breaks of any kind become \n, since a raw CR or LF would end the line.
+func icalEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\r", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icalFold writes one content line, folding it at 75 octets without
+// splitting a UTF-8 character.
+func icalFold(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// ICalendar renders events as an RFC 5545 calendar.
+func ICalendar(name string, events []Calendar_Event) string {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	icalFold(&b, "BEGIN:VCALENDAR")
+	icalFold(&b, "VERSION:2.0")
+	icalFold(&b, "PRODID:-//Gate//Study Plans//EN")
+	icalFold(&b, "CALSCALE:GREGORIAN")
+	icalFold(&b, "METHOD:PUBLISH")
+	icalFold(&b, "X-WR-CALNAME:"+icalEscape(name))
+	for _, event := range events {
+		icalFold(&b, "BEGIN:VEVENT")
+		icalFold(&b, "UID:"+event.UID)
+		icalFold(&b, "DTSTAMP:"+stamp)
+		icalFold(&b, "DTSTART;VALUE=DATE:"+event.Date.Format("20060102"))
+		icalFold(&b, "DTEND;VALUE=DATE:"+event.Date.AddDate(0, 0, 1).Format("20060102"))
+		icalFold(&b, "SUMMARY:"+icalEscape(event.Summary))
+		if event.Description != "" {
+			icalFold(&b, "DESCRIPTION:"+icalEscape(event.Description))
+		}
+		icalFold(&b, "TRANSP:TRANSPARENT")
+		icalFold(&b, "END:VEVENT")
+	}
+	icalFold(&b, "END:VCALENDAR")
+	return b.String()
+}
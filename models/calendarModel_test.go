@@ -0,0 +1,165 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestCalendarEvents(t *testing.T) {
+	title, url := "Intro", "https://example.com/intro"
+	intro := testMaterial("intro", 30, true)
+	intro.Material_Title = &title
+	intro.Material_Url = &url
+	plan := testPlan(3, 60, intro, testMaterial("reading", 80, false))
+	name := "Go basics"
+	plan.Plan_Name = &name
+
+	enrollment := Enrollment{Enrollment_id: "e1", Start: "2026-10-16", Weekdays_only: true}
+	events := enrollment.CalendarEvents(plan, map[string]bool{"intro": true})
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want one per study day", len(events))
+	}
+
+	first, second := events[0], events[1]
+	if first.UID != "e1-day-1@gate" || second.UID != "e1-day-2@gate" {
+		t.Errorf("uids = %s, %s", first.UID, second.UID)
+	}
+	// 16 October is a Friday, so the second day is the Monday after.
+	if got := first.Date.Format("2006-01-02") + " " + second.Date.Format("2006-01-02"); got != "2026-10-16 2026-10-19" {
+		t.Errorf("dates = %s", got)
+	}
+	if first.Summary != "Go basics: day 1 (60 min)" || second.Summary != "Go basics: day 2 (50 min)" {
+		t.Errorf("summaries = %q, %q", first.Summary, second.Summary)
+	}
+
+	for _, want := range []string{"- [done] Intro, 30 min", url, "- reading (part 1 of 2), 30 min"} {
+		if !strings.Contains(first.Description, want) {
+			t.Errorf("day 1 description %q lacks %q", first.Description, want)
+		}
+	}
+	if second.Description != "- reading (part 2 of 2), 50 min" {
+		t.Errorf("day 2 description = %q", second.Description)
+	}
+}
+
+func TestIcalEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain text", "plain text"},
+		{"a, b; c", `a\, b\; c`},
+		{`back\slash`, `back\\slash`},
+		{"line\nbreak", `line\nbreak`},
+		{"crlf\r\nbreak", `crlf\nbreak`},
+		{"cr\rbreak", `cr\nbreak`},
+		{"blank\r\n\r\nline", `blank\n\nline`},
+		{`\,`, `\\\,`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := icalEscape(tt.value); got != tt.want {
+			t.Errorf("icalEscape(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// unfold reverses RFC 5545 line folding.
+func unfold(folded string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "")
+}
+
+func TestIcalFold(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{"empty", "", 1},
+		{"short", "SUMMARY:Day 1", 1},
+		{"exactly 75 octets", strings.Repeat("a", 75), 1},
+		{"76 octets", strings.Repeat("a", 76), 2},
+		{"long", "DESCRIPTION:" + strings.Repeat("x", 200), 3},
+		{"multibyte characters", "SUMMARY:" + strings.Repeat("é", 60), 2},
+		{"multibyte at the fold", strings.Repeat("a", 74) + "€uro", 2},
+		{"four byte characters", strings.Repeat("😀", 40), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			icalFold(&b, tt.line)
+			folded := b.String()
+
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("%q does not end with CRLF", folded)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			if len(lines) != tt.lines {
+				t.Errorf("folded into %d lines, want %d: %q", len(lines), tt.lines, folded)
+			}
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets long", i, len(line))
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i, line)
+				}
+			}
+			if got := unfold(folded); got != tt.line {
+				t.Errorf("unfolds to %q, want %q", got, tt.line)
+			}
+		})
+	}
+}
+
+func TestICalendar(t *testing.T) {
+	events := []Calendar_Event{
+		{UID: "e1-day-1@gate", Date: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), Summary: "Plan: day 1 (60 min)", Description: "- Intro, 30 min\n  https://example.com/intro"},
+		{UID: "e1-day-2@gate", Date: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), Summary: "Plan: day 2 (45 min)"},
+	}
+	calendar := ICalendar("Gate: Plan", events)
+
+	if strings.Contains(strings.ReplaceAll(calendar, "\r\n", ""), "\n") {
+		t.Error("calendar has a line not ended with CRLF")
+	}
+	lines := strings.Split(unfold(calendar), "\r\n")
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("calendar is not wrapped in VCALENDAR: %q ... %q", lines[0], lines[len(lines)-1])
+	}
+
+	for _, want := range []string{
+		"VERSION:2.0",
+		"PRODID:-//Gate//Study Plans//EN",
+		"X-WR-CALNAME:Gate: Plan",
+		"UID:e1-day-1@gate",
+		"DTSTART;VALUE=DATE:20261231",
+		"DTEND;VALUE=DATE:20270101",
+		`SUMMARY:Plan: day 1 (60 min)`,
+		`DESCRIPTION:- Intro\, 30 min\n  https://example.com/intro`,
+		"DTSTART;VALUE=DATE:20270101",
+		"DTEND;VALUE=DATE:20270102",
+	} {
+		found := false
+		for _, line := range lines {
+			if line == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("calendar has no line %q", want)
+		}
+	}
+
+	if n := strings.Count(calendar, "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("calendar has %d events, want 2", n)
+	}
+	if n := strings.Count(calendar, "DESCRIPTION:"); n != 1 {
+		t.Errorf("calendar has %d descriptions, want 1 for the event that has one", n)
+	}
+}
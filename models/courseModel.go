@@ -1,17 +1,25 @@
 package models
 
 import (
+	"encoding/json"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Study_Plan lists its courses, and Course its materials, by id in order.
+// Courses and Course_Materials are only filled when a handler expands the
+// ids; they are never stored. Total_Duration is computed from the listed
+// materials or courses, never taken from the request.
 type Study_Plan struct {
 	ID             primitive.ObjectID `bson:"_id"`
 	Plan_Name      *string            `json:"plan_name" validate:"required,min=3"`
-	Number_Of_Days int64              `json:"number_of_days,string" validate:"required"`
-	Daily_Minutes  int64              `json:"daily_minutes,string"`
-	Courses        []Course           `json:"course" bson:"course"`
+	Number_Of_Days int64              `json:"number_of_days" validate:"gt=0"`
+	Daily_Minutes  int64              `json:"daily_minutes" validate:"gt=0"`
+	Total_Duration Duration           `json:"total_duration"`
+	Course_ids     []string           `json:"course_ids" bson:"course_ids"`
+	Courses        []Course           `json:"-" bson:"course,omitempty"`
 	Plan_id        string             `json:"plan_id"`
 	Created_at     time.Time          `json:"created_at"`
 	Updated_at     time.Time          `json:"updated_at"`
@@ -20,8 +28,9 @@ type Study_Plan struct {
 type Course struct {
 	ID               primitive.ObjectID `bson:"_id"`
 	Course_Name      *string            `json:"course_name" validate:"required,min=3"`
-	Total_Duration   time.Duration      `json:"total_duration,string"`
-	Course_Materials []Study_Material   `json:"course_materials" bson:"course_materials"`
+	Total_Duration   Duration           `json:"total_duration"`
+	Material_ids     []string           `json:"material_ids" bson:"material_ids"`
+	Course_Materials []Study_Material   `json:"-" bson:"course_materials,omitempty"`
 	Course_Id        string             `json:"course_id"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
@@ -31,10 +40,78 @@ type Study_Material struct {
 	ID             primitive.ObjectID `bson:"_id"`
 	Material_Title *string            `json:"material_title" validate:"required,min=3"`
 	Material_Url   *string            `json:"material_url" validate:"required"`
-	IsVideo        bool               `json:"isVideo,string"`
-	Time_Duration  time.Duration      `json:"time_duration,string"`
+	IsVideo        bool               `json:"is_video" bson:"isvideo"`
+	Time_Duration  Duration           `json:"time_duration" validate:"gte=0"`
 	Tags           []string           `json:"tags"`
 	Material_Id    string             `json:"material_id"`
 	Created_at     time.Time          `json:"created_at"`
 	Updated_at     time.Time          `json:"updated_at"`
 }
+
+// OrderMaterials puts expanded materials in the order of Material_ids. A
+// material listed twice appears twice, and ids without a stored material are
+// left out. A course that was not expanded is left alone.
+func (c *Course) OrderMaterials() {
+	if c.Course_Materials == nil {
+		return
+	}
+	byId := map[string]Study_Material{}
+	for _, material := range c.Course_Materials {
+		byId[material.Material_Id] = material
+	}
+	materials := []Study_Material{}
+	for _, id := range c.Material_ids {
+		if material, ok := byId[id]; ok {
+			materials = append(materials, material)
+		}
+	}
+	c.Course_Materials = materials
+}
+
+// OrderCourses puts expanded courses in the order of Course_ids, the same
+// way as OrderMaterials, and orders the materials of each.
+func (p *Study_Plan) OrderCourses() {
+	if p.Courses == nil {
+		return
+	}
+	byId := map[string]Course{}
+	for _, course := range p.Courses {
+		byId[course.Course_Id] = course
+	}
+	courses := []Course{}
+	for _, id := range p.Course_ids {
+		if course, ok := byId[id]; ok {
+			course.OrderMaterials()
+			courses = append(courses, course)
+		}
+	}
+	p.Courses = courses
+}
+
+// Duration is stored in nanoseconds like time.Duration and read and written
+// in JSON as a string of nanoseconds, the format the API has always used.
+// Responses also carry the duration in minutes, see Minutes.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(d), 10))
+}
+
+// UnmarshalJSON accepts the nanoseconds as a string or a plain number.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value json.Number
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	nanoseconds, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return err
+	}
+	*d = Duration(nanoseconds)
+	return nil
+}
+
+// Minutes rounds the duration up to whole minutes.
+func (d Duration) Minutes() int64 {
+	return int64((time.Duration(d) + time.Minute - 1) / time.Minute)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func materialIds(materials []Study_Material) []string {
+	if materials == nil {
+		return nil
+	}
+	ids := []string{}
+	for _, material := range materials {
+		ids = append(ids, material.Material_Id)
+	}
+	return ids
+}
+
+func TestOrderMaterials(t *testing.T) {
+	expanded := []Study_Material{{Material_Id: "a"}, {Material_Id: "b"}, {Material_Id: "c"}}
+
+	tests := []struct {
+		name      string
+		ids       []string
+		materials []Study_Material
+		want      []string
+	}{
+		{"listed order", []string{"c", "a", "b"}, expanded, []string{"c", "a", "b"}},
+		{"repeated id", []string{"a", "b", "a"}, expanded, []string{"a", "b", "a"}},
+		{"missing material", []string{"b", "gone", "a"}, expanded, []string{"b", "a"}},
+		{"material no longer listed", []string{"a"}, expanded, []string{"a"}},
+		{"no ids", nil, expanded, []string{}},
+		{"not expanded", []string{"a", "b"}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			course := Course{Material_ids: tt.ids, Course_Materials: tt.materials}
+			course.OrderMaterials()
+
+			if got := materialIds(course.Course_Materials); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("materials = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderCourses(t *testing.T) {
+	plan := Study_Plan{
+		Course_ids: []string{"second", "first", "second", "deleted"},
+		Courses: []Course{
+			{Course_Id: "first", Material_ids: []string{"b", "a"}, Course_Materials: []Study_Material{{Material_Id: "a"}, {Material_Id: "b"}}},
+			{Course_Id: "second", Material_ids: []string{"c"}, Course_Materials: []Study_Material{{Material_Id: "c"}}},
+		},
+	}
+	plan.OrderCourses()
+
+	got := [][]string{}
+	for _, course := range plan.Courses {
+		got = append(got, append([]string{course.Course_Id}, materialIds(course.Course_Materials)...))
+	}
+	want := [][]string{{"second", "c"}, {"first", "b", "a"}, {"second", "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("courses = %v, want %v", got, want)
+	}
+
+	unexpanded := Study_Plan{Course_ids: []string{"first"}}
+	unexpanded.OrderCourses()
+	if unexpanded.Courses != nil {
+		t.Errorf("courses of a plan that was not expanded = %v, want nil", unexpanded.Courses)
+	}
+}
+
+func TestDurationMinutes(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     int64
+	}{
+		{0, 0},
+		{time.Second, 1},
+		{time.Minute, 1},
+		{90 * time.Second, 2},
+		{45 * time.Minute, 45},
+	}
+	for _, tt := range tests {
+		if got := Duration(tt.duration).Minutes(); got != tt.want {
+			t.Errorf("Duration(%v).Minutes() = %d, want %d", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	data, err := json.Marshal(Study_Material{Time_Duration: Duration(90 * time.Second)}.Response())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["time_duration"] != "90000000000" || body["time_duration_minutes"] != float64(2) {
+		t.Errorf("material response = %s", data)
+	}
+
+	for _, input := range []string{`{"time_duration":"90000000000"}`, `{"time_duration":90000000000}`} {
+		var material Study_Material
+		if err := json.Unmarshal([]byte(input), &material); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if material.Time_Duration != Duration(90*time.Second) {
+			t.Errorf("%s: time_duration = %v, want %v", input, time.Duration(material.Time_Duration), 90*time.Second)
+		}
+	}
+}
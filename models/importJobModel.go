@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Import_Job tracks one CSV import of user accounts, which runs after the
+// upload request has returned.
+type Import_Job struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Import_job_id  string             `json:"import_job_id"`
+	Created_by     string             `json:"created_by"`
+	Status         string             `json:"status"`
+	Total_rows     int                `json:"total_rows"`
+	Processed_rows int                `json:"processed_rows"`
+	Created_count  int                `json:"created_count"`
+	Failed_count   int                `json:"failed_count"`
+	Errors         []Import_Row_Error `json:"errors"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Finished_at    *time.Time         `json:"finished_at,omitempty"`
+}
+
+// Import_Row_Error explains why one row was not imported. Row numbers count
+// the header as row 1, as spreadsheets do.
+type Import_Row_Error struct {
+	Row   int    `json:"row"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Enrollment is a student taking a study plan. The schedule options are kept
+// so progress can be compared with the schedule the student follows.
+// Calendar_token_hash is set while the enrollment has a calendar feed.
+type Enrollment struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Enrollment_id string             `json:"enrollment_id"`
+	User_id       string             `json:"user_id"`
+	Plan_id       string             `json:"plan_id"`
+	Start         string             `json:"start"`
+	Weekdays_only bool               `json:"weekdays_only"`
+	Rest_days     []string           `json:"rest_days"`
+	Enrolled_at   time.Time          `json:"enrolled_at"`
+
+	Calendar_token_hash *string `json:"-"`
+}
+
+// Completion records that a student finished a material. Completing it again
+// replaces the record.
+type Completion struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	User_id       string             `json:"user_id"`
+	Material_id   string             `json:"material_id"`
+	Minutes_spent int64              `json:"minutes_spent"`
+	Completed_at  time.Time          `json:"completed_at"`
+}
+
+type Course_Progress struct {
+	Course_id           string  `json:"course_id"`
+	Course_Name         *string `json:"course_name"`
+	Materials           int     `json:"materials"`
+	Completed_materials int     `json:"completed_materials"`
+	Percent_complete    float64 `json:"percent_complete"`
+	Planned_minutes     int64   `json:"planned_minutes"`
+	Completed_minutes   int64   `json:"completed_minutes"`
+	Minutes_studied     int64   `json:"minutes_studied"`
+}
+
+// Plan_Progress compares a student's completions with their plan. Completed
+// and planned minutes use the materials' durations; Minutes_studied is the
+// time the student reported. Minutes_ahead is negative when the student is
+// behind the schedule.
+type Plan_Progress struct {
+	Plan_id                   string            `json:"plan_id"`
+	Plan_Name                 *string           `json:"plan_name"`
+	Enrolled_at               time.Time         `json:"enrolled_at"`
+	Start                     string            `json:"start"`
+	Percent_complete          float64           `json:"percent_complete"`
+	Planned_minutes           int64             `json:"planned_minutes"`
+	Completed_minutes         int64             `json:"completed_minutes"`
+	Minutes_studied           int64             `json:"minutes_studied"`
+	Scheduled_minutes_to_date int64             `json:"scheduled_minutes_to_date"`
+	Minutes_ahead             int64             `json:"minutes_ahead"`
+	Status                    string            `json:"status"`
+	Courses                   []Course_Progress `json:"courses"`
+}
+
+// Schedule lays the expanded plan out with the enrollment's start and rest
+// days. An enrollment without a valid start uses the day it was made.
+func (e Enrollment) Schedule(plan Study_Plan) Study_Schedule {
+	start, err := time.Parse("2006-01-02", e.Start)
+	if err != nil {
+		start = e.Enrolled_at
+	}
+	rest, restNames, _ := ParseRestDays(strings.Join(e.Rest_days, ","), e.Weekdays_only)
+	return plan.Schedule(start, e.Weekdays_only, rest, restNames)
+}
+
+// percentOf returns part of whole as a percentage with one decimal.
+func percentOf(part int64, whole int64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
+
+// Progress measures the enrollment against the expanded plan and the
+// student's completions, which are keyed by material id.
+func (e Enrollment) Progress(plan Study_Plan, completions map[string]Completion, today time.Time) Plan_Progress {
+	progress := Plan_Progress{
+		Plan_id:     plan.Plan_id,
+		Plan_Name:   plan.Plan_Name,
+		Enrolled_at: e.Enrolled_at,
+		Start:       e.Start,
+		Courses:     []Course_Progress{},
+	}
+
+	var doneMaterials, totalMaterials int64
+	for _, course := range plan.Courses {
+		courseProgress := Course_Progress{Course_id: course.Course_Id, Course_Name: course.Course_Name}
+		for _, material := range course.Course_Materials {
+			minutes := material.Time_Duration.Minutes()
+			courseProgress.Materials++
+			courseProgress.Planned_minutes += minutes
+			if completion, ok := completions[material.Material_Id]; ok {
+				courseProgress.Completed_materials++
+				courseProgress.Completed_minutes += minutes
+				courseProgress.Minutes_studied += completion.Minutes_spent
+			}
+		}
+
+		// Courses made only of materials without a duration are measured
+		// by the number of materials instead.
+		if courseProgress.Planned_minutes > 0 {
+			courseProgress.Percent_complete = percentOf(courseProgress.Completed_minutes, courseProgress.Planned_minutes)
+		} else {
+			courseProgress.Percent_complete = percentOf(int64(courseProgress.Completed_materials), int64(courseProgress.Materials))
+		}
+
+		progress.Planned_minutes += courseProgress.Planned_minutes
+		progress.Completed_minutes += courseProgress.Completed_minutes
+		progress.Minutes_studied += courseProgress.Minutes_studied
+		doneMaterials += int64(courseProgress.Completed_materials)
+		totalMaterials += int64(courseProgress.Materials)
+		progress.Courses = append(progress.Courses, courseProgress)
+	}
+
+	if progress.Planned_minutes > 0 {
+		progress.Percent_complete = percentOf(progress.Completed_minutes, progress.Planned_minutes)
+	} else {
+		progress.Percent_complete = percentOf(doneMaterials, totalMaterials)
+	}
+
+	for _, day := range e.Schedule(plan).Days {
+		if day.Date <= today.Format("2006-01-02") {
+			progress.Scheduled_minutes_to_date += day.Minutes
+		}
+	}
+
+	progress.Minutes_ahead = progress.Completed_minutes - progress.Scheduled_minutes_to_date
+	switch {
+	case progress.Minutes_ahead > 0:
+		progress.Status = "ahead"
+	case progress.Minutes_ahead < 0:
+		progress.Status = "behind"
+	default:
+		progress.Status = "on_track"
+	}
+	return progress
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentOf(t *testing.T) {
+	tests := []struct {
+		part, whole int64
+		want        float64
+	}{
+		{0, 0, 0},
+		{5, 0, 0},
+		{0, 10, 0},
+		{1, 3, 33.3},
+		{2, 3, 66.7},
+		{10, 10, 100},
+	}
+	for _, tt := range tests {
+		if got := percentOf(tt.part, tt.whole); got != tt.want {
+			t.Errorf("percentOf(%d, %d) = %v, want %v", tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	enrolledAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	enrollment := Enrollment{Plan_id: "plan", Start: "2026-10-16", Enrolled_at: enrolledAt}
+	plan := testPlan(3, 60, testMaterial("a", 60, false), testMaterial("b", 60, false), testMaterial("c", 60, false))
+	// The schedule puts one material on each of 16, 17 and 18 October.
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	done := func(minutesSpent int64, ids ...string) map[string]Completion {
+		completions := map[string]Completion{}
+		for _, id := range ids {
+			completions[id] = Completion{Material_id: id, Minutes_spent: minutesSpent}
+		}
+		return completions
+	}
+
+	tests := []struct {
+		name        string
+		enrollment  Enrollment
+		plan        Study_Plan
+		completions map[string]Completion
+		today       time.Time
+		percent     float64
+		completed   int64
+		studied     int64
+		scheduled   int64
+		ahead       int64
+		status      string
+	}{
+		{
+			name:       "nothing done",
+			enrollment: enrollment, plan: plan, completions: done(0), today: day(17),
+			scheduled: 120, ahead: -120, status: "behind",
+		},
+		{
+			name:       "on schedule",
+			enrollment: enrollment, plan: plan, completions: done(50, "a", "b"), today: day(17),
+			percent: 66.7, completed: 120, studied: 100, scheduled: 120, status: "on_track",
+		},
+		{
+			name:       "ahead of schedule",
+			enrollment: enrollment, plan: plan, completions: done(30, "a", "b", "c"), today: day(17),
+			percent: 100, completed: 180, studied: 90, scheduled: 120, ahead: 60, status: "ahead",
+		},
+		{
+			name:       "before the start",
+			enrollment: enrollment, plan: plan, completions: done(0), today: day(10),
+			status: "on_track",
+		},
+		{
+			name:       "after the end",
+			enrollment: enrollment, plan: plan, completions: done(60, "a"), today: day(30),
+			percent: 33.3, completed: 60, studied: 60, scheduled: 180, ahead: -120, status: "behind",
+		},
+		{
+			name:       "without a start the enrollment date is used",
+			enrollment: Enrollment{Plan_id: "plan", Enrolled_at: enrolledAt}, plan: plan, completions: done(0), today: day(2),
+			scheduled: 120, ahead: -120, status: "behind",
+		},
+		{
+			name:       "materials without a duration count by number",
+			enrollment: enrollment, plan: testPlan(3, 60, testMaterial("a", 0, false), testMaterial("b", 0, false)),
+			completions: done(15, "a"), today: day(17),
+			percent: 50, studied: 15, status: "on_track",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := tt.enrollment.Progress(tt.plan, tt.completions, tt.today)
+
+			if progress.Percent_complete != tt.percent {
+				t.Errorf("percent complete = %v, want %v", progress.Percent_complete, tt.percent)
+			}
+			if progress.Completed_minutes != tt.completed {
+				t.Errorf("completed minutes = %d, want %d", progress.Completed_minutes, tt.completed)
+			}
+			if progress.Minutes_studied != tt.studied {
+				t.Errorf("minutes studied = %d, want %d", progress.Minutes_studied, tt.studied)
+			}
+			if progress.Scheduled_minutes_to_date != tt.scheduled {
+				t.Errorf("scheduled minutes to date = %d, want %d", progress.Scheduled_minutes_to_date, tt.scheduled)
+			}
+			if progress.Minutes_ahead != tt.ahead {
+				t.Errorf("minutes ahead = %d, want %d", progress.Minutes_ahead, tt.ahead)
+			}
+			if progress.Status != tt.status {
+				t.Errorf("status = %s, want %s", progress.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestProgressByCourse(t *testing.T) {
+	plan := Study_Plan{
+		Plan_id:        "plan",
+		Number_Of_Days: 5,
+		Daily_Minutes:  60,
+		Courses: []Course{
+			{Course_Id: "first", Course_Materials: []Study_Material{testMaterial("a", 30, false), testMaterial("b", 90, false)}},
+			{Course_Id: "second", Course_Materials: []Study_Material{testMaterial("c", 60, true)}},
+		},
+	}
+	completions := map[string]Completion{"b": {Material_id: "b", Minutes_spent: 80}, "c": {Material_id: "c", Minutes_spent: 60}}
+	enrollment := Enrollment{Start: "2026-10-16"}
+
+	progress := enrollment.Progress(plan, completions, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+
+	want := []Course_Progress{
+		{Course_id: "first", Materials: 2, Completed_materials: 1, Percent_complete: 75, Planned_minutes: 120, Completed_minutes: 90, Minutes_studied: 80},
+		{Course_id: "second", Materials: 1, Completed_materials: 1, Percent_complete: 100, Planned_minutes: 60, Completed_minutes: 60, Minutes_studied: 60},
+	}
+	if len(progress.Courses) != len(want) {
+		t.Fatalf("got %d courses, want %d", len(progress.Courses), len(want))
+	}
+	for i := range want {
+		if progress.Courses[i] != want[i] {
+			t.Errorf("course %d = %+v, want %+v", i, progress.Courses[i], want[i])
+		}
+	}
+	if progress.Percent_complete != 83.3 || progress.Planned_minutes != 180 || progress.Completed_minutes != 150 {
+		t.Errorf("plan progress = %v%%, %d of %d minutes", progress.Percent_complete, progress.Completed_minutes, progress.Planned_minutes)
+	}
+}
@@ -0,0 +1,342 @@
+package models
+
+import "time"
+
+// The types in this file are what handlers send back. They list every field
+// explicitly, so password hashes, token hashes and secrets stored on the
+// models cannot reach a response.
+
+// Public_User is a user as they see themselves.
+type Public_User struct {
+	User_id        string    `json:"user_id"`
+	First_Name     *string   `json:"first_name"`
+	Last_Name      *string   `json:"last_name"`
+	Email          *string   `json:"email"`
+	Phone          *string   `json:"phone"`
+	User_type      *string   `json:"user_type"`
+	Email_verified bool      `json:"email_verified"`
+	Pending_email  *string   `json:"pending_email,omitempty"`
+	Totp_enabled   bool      `json:"totp_enabled"`
+	Created_at     time.Time `json:"created_at"`
+	Updated_at     time.Time `json:"updated_at"`
+}
+
+func (u User) Public() Public_User {
+	return Public_User{
+		User_id:        u.User_id,
+		First_Name:     u.First_Name,
+		Last_Name:      u.Last_Name,
+		Email:          u.Email,
+		Phone:          u.Phone,
+		User_type:      u.User_type,
+		Email_verified: u.Email_verified,
+		Pending_email:  u.Pending_email,
+		Totp_enabled:   u.Totp_enabled,
+		Created_at:     u.Created_at,
+		Updated_at:     u.Updated_at,
+	}
+}
+
+// Admin_User is a user as seen by user administrators. Its bson keys match
+// the user collection so lists can decode straight into it.
+type Admin_User struct {
+	User_id         string     `json:"user_id"`
+	First_Name      *string    `json:"first_name"`
+	Last_Name       *string    `json:"last_name"`
+	Email           *string    `json:"email"`
+	Phone           *string    `json:"phone"`
+	User_type       *string    `json:"user_type"`
+	Email_verified  *bool      `json:"email_verified"`
+	Pending_email   *string    `json:"pending_email,omitempty"`
+	Totp_enabled    bool       `json:"totp_enabled"`
+	Created_at      time.Time  `json:"created_at"`
+	Updated_at      time.Time  `json:"updated_at"`
+	Role_updated_by string     `json:"role_updated_by,omitempty"`
+	Role_updated_at *time.Time `json:"role_updated_at,omitempty"`
+	Mentor_id       *string    `json:"mentor_id,omitempty"`
+	Deactivated_at  *time.Time `json:"deactivated_at,omitempty"`
+	Deleted_at      *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (u User) Admin() Admin_User {
+	verified := u.Email_verified
+	return Admin_User{
+		User_id:         u.User_id,
+		First_Name:      u.First_Name,
+		Last_Name:       u.Last_Name,
+		Email:           u.Email,
+		Phone:           u.Phone,
+		User_type:       u.User_type,
+		Email_verified:  &verified,
+		Pending_email:   u.Pending_email,
+		Totp_enabled:    u.Totp_enabled,
+		Created_at:      u.Created_at,
+		Updated_at:      u.Updated_at,
+		Role_updated_by: u.Role_updated_by,
+		Role_updated_at: u.Role_updated_at,
+		Mentor_id:       u.Mentor_id,
+		Deactivated_at:  u.Deactivated_at,
+		Deleted_at:      u.Deleted_at,
+	}
+}
+
+// Login_Response is the user and the tokens of their new session.
+type Login_Response struct {
+	Public_User
+	Token         string `json:"token"`
+	Refresh_token string `json:"refresh_token"`
+}
+
+type Study_Material_Response struct {
+	Material_Id    string    `json:"material_id"`
+	Material_Title *string   `json:"material_title"`
+	Material_Url   *string   `json:"material_url"`
+	IsVideo        bool      `json:"is_video"`
+	Time_Duration  Duration  `json:"time_duration"`
+	Time_Minutes   int64     `json:"time_duration_minutes"`
+	Tags           []string  `json:"tags"`
+	Created_at     time.Time `json:"created_at"`
+	Updated_at     time.Time `json:"updated_at"`
+}
+
+func (m Study_Material) Response() Study_Material_Response {
+	return Study_Material_Response{
+		Material_Id:    m.Material_Id,
+		Material_Title: m.Material_Title,
+		Material_Url:   m.Material_Url,
+		IsVideo:        m.IsVideo,
+		Time_Duration:  m.Time_Duration,
+		Time_Minutes:   m.Time_Duration.Minutes(),
+		Tags:           m.Tags,
+		Created_at:     m.Created_at,
+		Updated_at:     m.Updated_at,
+	}
+}
+
+type Course_Response struct {
+	Course_Id        string                    `json:"course_id"`
+	Course_Name      *string                   `json:"course_name"`
+	Total_Duration   Duration                  `json:"total_duration"`
+	Total_Minutes    int64                     `json:"total_duration_minutes"`
+	Material_ids     []string                  `json:"material_ids"`
+	Course_Materials []Study_Material_Response `json:"course_materials,omitempty"`
+	Created_at       time.Time                 `json:"created_at"`
+	Updated_at       time.Time                 `json:"updated_at"`
+}
+
+func (c Course) Response() Course_Response {
+	var materials []Study_Material_Response
+	if c.Course_Materials != nil {
+		materials = []Study_Material_Response{}
+	}
+	for _, material := range c.Course_Materials {
+		materials = append(materials, material.Response())
+	}
+	materialIds := c.Material_ids
+	if materialIds == nil {
+		materialIds = []string{}
+	}
+	return Course_Response{
+		Course_Id:        c.Course_Id,
+		Course_Name:      c.Course_Name,
+		Total_Duration:   c.Total_Duration,
+		Total_Minutes:    c.Total_Duration.Minutes(),
+		Material_ids:     materialIds,
+		Course_Materials: materials,
+		Created_at:       c.Created_at,
+		Updated_at:       c.Updated_at,
+	}
+}
+
+type Study_Plan_Response struct {
+	Plan_id        string            `json:"plan_id"`
+	Plan_Name      *string           `json:"plan_name"`
+	Number_Of_Days int64             `json:"number_of_days"`
+	Daily_Minutes  int64             `json:"daily_minutes"`
+	Total_Duration Duration          `json:"total_duration"`
+	Total_Minutes  int64             `json:"total_duration_minutes"`
+	Course_ids     []string          `json:"course_ids"`
+	Courses        []Course_Response `json:"course,omitempty"`
+	Created_at     time.Time         `json:"created_at"`
+	Updated_at     time.Time         `json:"updated_at"`
+}
+
+func (p Study_Plan) Response() Study_Plan_Response {
+	var courses []Course_Response
+	if p.Courses != nil {
+		courses = []Course_Response{}
+	}
+	for _, course := range p.Courses {
+		courses = append(courses, course.Response())
+	}
+	courseIds := p.Course_ids
+	if courseIds == nil {
+		courseIds = []string{}
+	}
+	return Study_Plan_Response{
+		Plan_id:        p.Plan_id,
+		Plan_Name:      p.Plan_Name,
+		Number_Of_Days: p.Number_Of_Days,
+		Daily_Minutes:  p.Daily_Minutes,
+		Total_Duration: p.Total_Duration,
+		Total_Minutes:  p.Total_Duration.Minutes(),
+		Course_ids:     courseIds,
+		Courses:        courses,
+		Created_at:     p.Created_at,
+		Updated_at:     p.Updated_at,
+	}
+}
+
+type Role_Response struct {
+	Name        *string   `json:"name"`
+	Permissions []string  `json:"permissions"`
+	Created_at  time.Time `json:"created_at"`
+	Updated_at  time.Time `json:"updated_at"`
+}
+
+func (r Role) Response() Role_Response {
+	return Role_Response{
+		Name:        r.Name,
+		Permissions: r.Permissions,
+		Created_at:  r.Created_at,
+		Updated_at:  r.Updated_at,
+	}
+}
+
+type Role_Change_Response struct {
+	User_id    string    `json:"user_id"`
+	Changed_by string    `json:"changed_by"`
+	From       *string   `json:"from"`
+	To         *string   `json:"to"`
+	Changed_at time.Time `json:"changed_at"`
+}
+
+func (r Role_Change) Response() Role_Change_Response {
+	return Role_Change_Response{
+		User_id:    r.User_id,
+		Changed_by: r.Changed_by,
+		From:       r.From,
+		To:         r.To,
+		Changed_at: r.Changed_at,
+	}
+}
+
+type Api_Key_Response struct {
+	Api_key_id   string     `json:"api_key_id"`
+	Name         *string    `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	Prefix       string     `json:"prefix"`
+	Expires_at   *time.Time `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at"`
+	Revoked_at   *time.Time `json:"revoked_at"`
+	Created_at   time.Time  `json:"created_at"`
+}
+
+func (k Api_Key) Response() Api_Key_Response {
+	return Api_Key_Response{
+		Api_key_id:   k.Api_key_id,
+		Name:         k.Name,
+		Scopes:       k.Scopes,
+		Prefix:       k.Prefix,
+		Expires_at:   k.Expires_at,
+		Last_used_at: k.Last_used_at,
+		Revoked_at:   k.Revoked_at,
+		Created_at:   k.Created_at,
+	}
+}
+
+type Session_Response struct {
+	Session_id   string     `json:"session_id"`
+	User_agent   string     `json:"user_agent"`
+	Ip           string     `json:"ip"`
+	Current      bool       `json:"current"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Last_seen_at time.Time  `json:"last_seen_at"`
+}
+
+func (s Session) Response() Session_Response {
+	return Session_Response{
+		Session_id:   s.Session_id,
+		User_agent:   s.User_agent,
+		Ip:           s.Ip,
+		Current:      s.Current,
+		Revoked_at:   s.Revoked_at,
+		Created_at:   s.Created_at,
+		Last_seen_at: s.Last_seen_at,
+	}
+}
+
+type Import_Job_Response struct {
+	Import_job_id  string             `json:"import_job_id"`
+	Created_by     string             `json:"created_by"`
+	Status         string             `json:"status"`
+	Total_rows     int                `json:"total_rows"`
+	Processed_rows int                `json:"processed_rows"`
+	Created_count  int                `json:"created_count"`
+	Failed_count   int                `json:"failed_count"`
+	Errors         []Import_Row_Error `json:"errors"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Finished_at    *time.Time         `json:"finished_at,omitempty"`
+}
+
+func (j Import_Job) Response() Import_Job_Response {
+	errors := j.Errors
+	if errors == nil {
+		errors = []Import_Row_Error{}
+	}
+	return Import_Job_Response{
+		Import_job_id:  j.Import_job_id,
+		Created_by:     j.Created_by,
+		Status:         j.Status,
+		Total_rows:     j.Total_rows,
+		Processed_rows: j.Processed_rows,
+		Created_count:  j.Created_count,
+		Failed_count:   j.Failed_count,
+		Errors:         errors,
+		Created_at:     j.Created_at,
+		Updated_at:     j.Updated_at,
+		Finished_at:    j.Finished_at,
+	}
+}
+
+type Enrollment_Response struct {
+	Enrollment_id string    `json:"enrollment_id"`
+	Plan_id       string    `json:"plan_id"`
+	Start         string    `json:"start"`
+	Weekdays_only bool      `json:"weekdays_only"`
+	Rest_days     []string  `json:"rest_days"`
+	Enrolled_at   time.Time `json:"enrolled_at"`
+	Calendar_feed bool      `json:"calendar_feed"`
+}
+
+func (e Enrollment) Response() Enrollment_Response {
+	restDays := e.Rest_days
+	if restDays == nil {
+		restDays = []string{}
+	}
+	return Enrollment_Response{
+		Enrollment_id: e.Enrollment_id,
+		Plan_id:       e.Plan_id,
+		Start:         e.Start,
+		Weekdays_only: e.Weekdays_only,
+		Rest_days:     restDays,
+		Enrolled_at:   e.Enrolled_at,
+		Calendar_feed: e.Calendar_token_hash != nil,
+	}
+}
+
+type Completion_Response struct {
+	Material_id   string    `json:"material_id"`
+	Minutes_spent int64     `json:"minutes_spent"`
+	Completed_at  time.Time `json:"completed_at"`
+}
+
+func (c Completion) Response() Completion_Response {
+	return Completion_Response{
+		Material_id:   c.Material_id,
+		Minutes_spent: c.Minutes_spent,
+		Completed_at:  c.Completed_at,
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// secret marks values that must never reach a response.
+func secret(name string) *string {
+	value := "SECRET-" + name
+	return &value
+}
+
+func sensitiveUser() User {
+	first, last, email, phone, userType := "Ada", "Lovelace", "ada@example.com", "+4400000000", "USER"
+	expires := time.Now().Add(time.Hour)
+	return User{
+		ID:                            primitive.NewObjectID(),
+		First_Name:                    &first,
+		Last_Name:                     &last,
+		Email:                         &email,
+		Phone:                         &phone,
+		User_type:                     &userType,
+		User_id:                       "user-1",
+		Password:                      secret("password"),
+		Totp_enabled:                  true,
+		Totp_secret:                   secret("totp"),
+		Totp_last_step:                123456,
+		Recovery_codes:                []string{*secret("recovery")},
+		Oidc_issuer:                   *secret("issuer"),
+		Oidc_subject:                  *secret("subject"),
+		Email_verification_hash:       secret("verification"),
+		Email_verification_expires_at: &expires,
+		Password_reset_hash:           secret("reset"),
+		Password_reset_expires_at:     &expires,
+	}
+}
+
+func TestResponsesLeaveOutSecrets(t *testing.T) {
+	user := sensitiveUser()
+	name := "laptop"
+
+	tests := []struct {
+		name     string
+		response interface{}
+	}{
+		{"public user", user.Public()},
+		{"admin user", user.Admin()},
+		{"login", Login_Response{Public_User: user.Public(), Token: "access", Refresh_token: "refresh"}},
+		{"session", Session{
+			Session_id:         "session-1",
+			User_id:            "user-1",
+			Refresh_token_hash: *secret("refresh-hash"),
+			Access_jti:         *secret("access-jti"),
+			Refresh_jti:        *secret("refresh-jti"),
+		}.Response()},
+		{"api key", Api_Key{
+			Api_key_id: "key-1",
+			Name:       &name,
+			Scopes:     []string{"account:self"},
+			Prefix:     "gk_abcd",
+			Key_hash:   *secret("key-hash"),
+			User_id:    "user-1",
+		}.Response()},
+		{"enrollment", Enrollment{
+			Enrollment_id:       "enrollment-1",
+			User_id:             "user-1",
+			Plan_id:             "plan-1",
+			Calendar_token_hash: secret("calendar-hash"),
+		}.Response()},
+	}
+
+	forbiddenKeys := []string{
+		`"password"`, `"totp_secret"`, `"totp_last_step"`, `"recovery_codes"`,
+		`"oidc_issuer"`, `"oidc_subject"`, `_hash"`, `"email_verification_expires_at"`,
+		`"password_reset_expires_at"`, `"access_jti"`, `"refresh_jti"`,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := string(data)
+
+			if strings.Contains(body, "SECRET-") {
+				t.Errorf("response contains a secret value: %s", body)
+			}
+			for _, key := range forbiddenKeys {
+				if strings.Contains(body, key) {
+					t.Errorf("response contains %s: %s", key, body)
+				}
+			}
+		})
+	}
+}
+
+// The login response is the only one that carries tokens, and only the
+// session's own.
+func TestOnlyLoginResponseCarriesTokens(t *testing.T) {
+	user := sensitiveUser()
+
+	for name, response := range map[string]interface{}{"public user": user.Public(), "admin user": user.Admin()} {
+		data, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"token"`) || strings.Contains(string(data), `"refresh_token"`) {
+			t.Errorf("%s response contains a token: %s", name, data)
+		}
+	}
+
+	data, err := json.Marshal(Login_Response{Public_User: user.Public(), Token: "access", Refresh_token: "refresh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var login map[string]interface{}
+	if err := json.Unmarshal(data, &login); err != nil {
+		t.Fatal(err)
+	}
+	if login["token"] != "access" || login["refresh_token"] != "refresh" || login["user_id"] != "user-1" {
+		t.Errorf("login response = %s", data)
+	}
+}
+
+// Stored models keep their secrets out of JSON too, in case one is ever
+// written to a response by mistake.
+func TestModelsHideSecretsFromJSON(t *testing.T) {
+	data, err := json.Marshal(sensitiveUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"totp", "recovery", "issuer", "subject", "verification", "reset"} {
+		if strings.Contains(string(data), "SECRET-"+value) {
+			t.Errorf("user JSON contains the %s secret: %s", value, data)
+		}
+	}
+}
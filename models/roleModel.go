@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=3,uppercase"`
+	Permissions []string           `json:"permissions"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+}
+
+// Role_Change records who changed a user's role and from what.
+type Role_Change struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	User_id    string             `json:"user_id"`
+	Changed_by string             `json:"changed_by"`
+	From       *string            `json:"from"`
+	To         *string            `json:"to"`
+	Changed_at time.Time          `json:"changed_at"`
+}
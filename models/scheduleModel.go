@@ -0,0 +1,267 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Plan_Day is one day of a study plan: the materials studied that day and
+// how many minutes they take beyond the day's budget.
+type Plan_Day struct {
+	Day              int      `json:"day"`
+	Minutes          int64    `json:"minutes"`
+	Overflow_minutes int64    `json:"overflow_minutes"`
+	Material_ids     []string `json:"material_ids"`
+}
+
+// Plan_Feasibility compares the time a plan's courses need with the time its
+// days allow. Days past Number_Of_Days have no budget, so all of their
+// minutes overflow.
+type Plan_Feasibility struct {
+	Plan_id           string     `json:"plan_id"`
+	Number_Of_Days    int64      `json:"number_of_days"`
+	Daily_Minutes     int64      `json:"daily_minutes"`
+	Total_Minutes     int64      `json:"total_minutes"`
+	Available_Minutes int64      `json:"available_minutes"`
+	Feasible          bool       `json:"feasible"`
+	Days_needed       int        `json:"days_needed"`
+	Overflow_days     []int      `json:"overflow_days"`
+	Days              []Plan_Day `json:"days"`
+}
+
+// Schedule_Item is one material, or one part of a material, on a day. Part
+// and Parts are set when a reading was split over several days.
+type Schedule_Item struct {
+	Course_id      string  `json:"course_id"`
+	Material_id    string  `json:"material_id"`
+	Material_title *string `json:"material_title"`
+	Material_url   *string `json:"material_url"`
+	Is_video       bool    `json:"is_video"`
+	Minutes        int64   `json:"minutes"`
+	Part           int     `json:"part,omitempty"`
+	Parts          int     `json:"parts,omitempty"`
+}
+
+type Schedule_Day struct {
+	Day              int             `json:"day"`
+	Date             string          `json:"date,omitempty"`
+	Weekday          string          `json:"weekday,omitempty"`
+	Minutes          int64           `json:"minutes"`
+	Overflow_minutes int64           `json:"overflow_minutes"`
+	Items            []Schedule_Item `json:"items"`
+}
+
+// Study_Schedule lays a plan out on the calendar from Start, skipping rest
+// days. Feasible is false when it needs more study days than the plan has
+// or a day runs over the daily budget.
+type Study_Schedule struct {
+	Plan_id        string         `json:"plan_id"`
+	Plan_Name      *string        `json:"plan_name"`
+	Start          string         `json:"start"`
+	End            string         `json:"end"`
+	Number_Of_Days int64          `json:"number_of_days"`
+	Daily_Minutes  int64          `json:"daily_minutes"`
+	Weekdays_only  bool           `json:"weekdays_only"`
+	Rest_days      []string       `json:"rest_days"`
+	Feasible       bool           `json:"feasible"`
+	Days           []Schedule_Day `json:"days"`
+}
+
+// A reading is only split when both parts get at least this many minutes.
+const minSplitMinutes = 10
+
+// pack places the materials of an expanded plan on numbered days in course
+// order within the daily budget. A material that does not fit in what is
+// left of a day moves to the next day. Readings are split across days
+// instead when both parts are long enough; videos never are, so a video
+// longer than the budget gets a day of its own and overflows it.
+func (p Study_Plan) pack() []Schedule_Day {
+	daily := p.Daily_Minutes
+	days := []Schedule_Day{}
+	newDay := func() *Schedule_Day {
+		days = append(days, Schedule_Day{Day: len(days) + 1, Items: []Schedule_Item{}})
+		return &days[len(days)-1]
+	}
+
+	for _, course := range p.Courses {
+		for _, material := range course.Course_Materials {
+			item := Schedule_Item{
+				Course_id:      course.Course_Id,
+				Material_id:    material.Material_Id,
+				Material_title: material.Material_Title,
+				Material_url:   material.Material_Url,
+				Is_video:       material.IsVideo,
+			}
+
+			type piece struct{ day, index int }
+			pieces := []piece{}
+			remaining := material.Time_Duration.Minutes()
+			for {
+				if len(days) == 0 {
+					newDay()
+				}
+				day := &days[len(days)-1]
+				free := daily - day.Minutes
+
+				minutes := remaining
+				if remaining > free {
+					canSplit := !material.IsVideo && free >= minSplitMinutes && remaining-free >= minSplitMinutes
+					if canSplit {
+						minutes = free
+					} else if day.Minutes > 0 {
+						newDay()
+						continue
+					}
+				}
+
+				item.Minutes = minutes
+				day.Items = append(day.Items, item)
+				day.Minutes += minutes
+				pieces = append(pieces, piece{len(days) - 1, len(day.Items) - 1})
+
+				remaining -= minutes
+				if remaining <= 0 {
+					break
+				}
+			}
+
+			if len(pieces) > 1 {
+				for i, piece := range pieces {
+					days[piece.day].Items[piece.index].Part = i + 1
+					days[piece.day].Items[piece.index].Parts = len(pieces)
+				}
+			}
+		}
+	}
+
+	for i := range days {
+		if days[i].Minutes > daily {
+			days[i].Overflow_minutes = days[i].Minutes - daily
+		}
+	}
+	return days
+}
+
+// Feasibility reports whether the materials of an expanded plan fit in its
+// days.
+func (p Study_Plan) Feasibility() Plan_Feasibility {
+	report := Plan_Feasibility{
+		Plan_id:           p.Plan_id,
+		Number_Of_Days:    p.Number_Of_Days,
+		Daily_Minutes:     p.Daily_Minutes,
+		Available_Minutes: p.Number_Of_Days * p.Daily_Minutes,
+		Overflow_days:     []int{},
+		Days:              []Plan_Day{},
+	}
+
+	for _, scheduled := range p.pack() {
+		day := Plan_Day{Day: scheduled.Day, Minutes: scheduled.Minutes, Overflow_minutes: scheduled.Overflow_minutes, Material_ids: []string{}}
+		for _, item := range scheduled.Items {
+			if n := len(day.Material_ids); n == 0 || day.Material_ids[n-1] != item.Material_id {
+				day.Material_ids = append(day.Material_ids, item.Material_id)
+			}
+		}
+		if int64(day.Day) > p.Number_Of_Days {
+			day.Overflow_minutes = day.Minutes
+		}
+		if day.Overflow_minutes > 0 {
+			report.Overflow_days = append(report.Overflow_days, day.Day)
+		}
+		report.Total_Minutes += day.Minutes
+		report.Days = append(report.Days, day)
+	}
+
+	report.Days_needed = len(report.Days)
+	report.Feasible = len(report.Overflow_days) == 0
+	return report
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Rest_Days holds the days the student does not study.
+type Rest_Days struct {
+	weekdays map[time.Weekday]bool
+	dates    map[string]bool
+}
+
+// ParseRestDays reads a comma separated list of weekday names, such as "sun"
+// or "sunday", and dates in YYYY-MM-DD form. It also returns the entries it
+// read, for the response.
+func ParseRestDays(value string, weekdaysOnly bool) (Rest_Days, []string, error) {
+	rest := Rest_Days{weekdays: map[time.Weekday]bool{}, dates: map[string]bool{}}
+	names := []string{}
+	if weekdaysOnly {
+		rest.weekdays[time.Saturday] = true
+		rest.weekdays[time.Sunday] = true
+	}
+
+	for _, token := range strings.Split(value, ",") {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		if len(token) >= 3 {
+			if weekday, ok := weekdayNames[token[:3]]; ok && strings.HasPrefix(strings.ToLower(weekday.String()), token) {
+				rest.weekdays[weekday] = true
+				names = append(names, token)
+				continue
+			}
+		}
+		if _, err := time.Parse("2006-01-02", token); err != nil {
+			return rest, nil, errors.New("rest_days must list weekday names or YYYY-MM-DD dates, not " + token)
+		}
+		rest.dates[token] = true
+		names = append(names, token)
+	}
+
+	if len(rest.weekdays) == 7 {
+		return rest, nil, errors.New("rest_days leave no day to study")
+	}
+	return rest, names, nil
+}
+
+func (r Rest_Days) includes(date time.Time) bool {
+	return r.weekdays[date.Weekday()] || r.dates[date.Format("2006-01-02")]
+}
+
+// Schedule packs an expanded plan and gives each day a date from start on,
+// skipping rest days.
+func (p Study_Plan) Schedule(start time.Time, weekdaysOnly bool, rest Rest_Days, restNames []string) Study_Schedule {
+	schedule := Study_Schedule{
+		Plan_id:        p.Plan_id,
+		Plan_Name:      p.Plan_Name,
+		Start:          start.Format("2006-01-02"),
+		End:            start.Format("2006-01-02"),
+		Number_Of_Days: p.Number_Of_Days,
+		Daily_Minutes:  p.Daily_Minutes,
+		Weekdays_only:  weekdaysOnly,
+		Rest_days:      restNames,
+		Days:           p.pack(),
+	}
+
+	date := start
+	feasible := int64(len(schedule.Days)) <= p.Number_Of_Days
+	for i := range schedule.Days {
+		for rest.includes(date) {
+			date = date.AddDate(0, 0, 1)
+		}
+		schedule.Days[i].Date = date.Format("2006-01-02")
+		schedule.Days[i].Weekday = date.Weekday().String()
+		schedule.End = schedule.Days[i].Date
+		if schedule.Days[i].Overflow_minutes > 0 {
+			feasible = false
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	schedule.Feasible = feasible
+	return schedule
+}
@@ -0,0 +1,353 @@
+package models
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testMaterial is a material of the given length in minutes.
+func testMaterial(id string, minutes int64, video bool) Study_Material {
+	return Study_Material{
+		Material_Id:   id,
+		IsVideo:       video,
+		Time_Duration: Duration(time.Duration(minutes) * time.Minute),
+	}
+}
+
+// testPlan is an expanded plan with one course holding the materials.
+func testPlan(numberOfDays int64, dailyMinutes int64, materials ...Study_Material) Study_Plan {
+	return Study_Plan{
+		Plan_id:        "plan",
+		Number_Of_Days: numberOfDays,
+		Daily_Minutes:  dailyMinutes,
+		Courses:        []Course{{Course_Id: "course", Course_Materials: materials}},
+	}
+}
+
+func TestFeasibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		plan         Study_Plan
+		feasible     bool
+		total        int64
+		daysNeeded   int
+		overflowDays []int
+		materials    [][]string
+	}{
+		{
+			name:       "fits",
+			plan:       testPlan(2, 60, testMaterial("a", 30, false), testMaterial("b", 30, false), testMaterial("c", 30, false)),
+			feasible:   true,
+			total:      90,
+			daysNeeded: 2,
+			materials:  [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			name:         "needs more days than the plan has",
+			plan:         testPlan(1, 60, testMaterial("a", 30, false), testMaterial("b", 30, false), testMaterial("c", 30, false)),
+			total:        90,
+			daysNeeded:   2,
+			overflowDays: []int{2},
+			materials:    [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			name:         "video longer than a day",
+			plan:         testPlan(3, 30, testMaterial("v", 45, true)),
+			total:        45,
+			daysNeeded:   1,
+			overflowDays: []int{1},
+			materials:    [][]string{{"v"}},
+		},
+		{
+			name:       "reading split over two days",
+			plan:       testPlan(2, 60, testMaterial("r", 100, false)),
+			feasible:   true,
+			total:      100,
+			daysNeeded: 2,
+			materials:  [][]string{{"r"}, {"r"}},
+		},
+		{
+			name:      "empty plan",
+			plan:      testPlan(5, 60),
+			feasible:  true,
+			materials: [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := tt.plan.Feasibility()
+
+			if report.Feasible != tt.feasible {
+				t.Errorf("feasible = %v, want %v", report.Feasible, tt.feasible)
+			}
+			if report.Total_Minutes != tt.total {
+				t.Errorf("total minutes = %d, want %d", report.Total_Minutes, tt.total)
+			}
+			if report.Available_Minutes != tt.plan.Number_Of_Days*tt.plan.Daily_Minutes {
+				t.Errorf("available minutes = %d", report.Available_Minutes)
+			}
+			if report.Days_needed != tt.daysNeeded {
+				t.Errorf("days needed = %d, want %d", report.Days_needed, tt.daysNeeded)
+			}
+
+			overflowDays := tt.overflowDays
+			if overflowDays == nil {
+				overflowDays = []int{}
+			}
+			if !reflect.DeepEqual(report.Overflow_days, overflowDays) {
+				t.Errorf("overflow days = %v, want %v", report.Overflow_days, overflowDays)
+			}
+
+			materials := [][]string{}
+			for _, day := range report.Days {
+				materials = append(materials, day.Material_ids)
+			}
+			if !reflect.DeepEqual(materials, tt.materials) {
+				t.Errorf("materials by day = %v, want %v", materials, tt.materials)
+			}
+		})
+	}
+}
+
+// packed describes a packed day as material:minutes, with /part for split
+// readings.
+func packed(days []Schedule_Day) [][]string {
+	out := [][]string{}
+	for _, day := range days {
+		items := []string{}
+		for _, item := range day.Items {
+			s := item.Material_id + ":" + strconv.FormatInt(item.Minutes, 10)
+			if item.Parts > 0 {
+				s += "/" + strconv.Itoa(item.Part) + "of" + strconv.Itoa(item.Parts)
+			}
+			items = append(items, s)
+		}
+		out = append(out, items)
+	}
+	return out
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     Study_Plan
+		want     [][]string
+		overflow []int64
+	}{
+		{
+			name:     "materials fill days in order",
+			plan:     testPlan(3, 60, testMaterial("a", 40, false), testMaterial("b", 20, false), testMaterial("c", 30, false)),
+			want:     [][]string{{"a:40", "b:20"}, {"c:30"}},
+			overflow: []int64{0, 0},
+		},
+		{
+			name:     "reading split across days",
+			plan:     testPlan(3, 60, testMaterial("a", 40, false), testMaterial("r", 50, false)),
+			want:     [][]string{{"a:40", "r:20/1of2"}, {"r:30/2of2"}},
+			overflow: []int64{0, 0},
+		},
+		{
+			name:     "long reading split into three",
+			plan:     testPlan(3, 60, testMaterial("r", 130, false)),
+			want:     [][]string{{"r:60/1of3"}, {"r:60/2of3"}, {"r:10/3of3"}},
+			overflow: []int64{0, 0, 0},
+		},
+		{
+			name:     "reading not split when what is left of the day is too short",
+			plan:     testPlan(3, 60, testMaterial("a", 55, false), testMaterial("r", 20, false)),
+			want:     [][]string{{"a:55"}, {"r:20"}},
+			overflow: []int64{0, 0},
+		},
+		{
+			name:     "reading not split when its second part would be too short",
+			plan:     testPlan(3, 60, testMaterial("r", 65, false)),
+			want:     [][]string{{"r:65"}},
+			overflow: []int64{5},
+		},
+		{
+			name:     "video never split",
+			plan:     testPlan(3, 60, testMaterial("a", 40, false), testMaterial("v", 30, true)),
+			want:     [][]string{{"a:40"}, {"v:30"}},
+			overflow: []int64{0, 0},
+		},
+		{
+			name:     "video longer than a day gets its own day",
+			plan:     testPlan(3, 30, testMaterial("a", 10, false), testMaterial("v", 45, true), testMaterial("b", 10, false)),
+			want:     [][]string{{"a:10"}, {"v:45"}, {"b:10"}},
+			overflow: []int64{0, 15, 0},
+		},
+		{
+			name:     "materials without a duration",
+			plan:     testPlan(1, 60, testMaterial("a", 0, false), testMaterial("b", 0, true)),
+			want:     [][]string{{"a:0", "b:0"}},
+			overflow: []int64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := tt.plan.pack()
+
+			if got := packed(days); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("days = %v, want %v", got, tt.want)
+			}
+			overflow := []int64{}
+			for i, day := range days {
+				if day.Day != i+1 {
+					t.Errorf("day %d is numbered %d", i+1, day.Day)
+				}
+				overflow = append(overflow, day.Overflow_minutes)
+			}
+			if !reflect.DeepEqual(overflow, tt.overflow) {
+				t.Errorf("overflow = %v, want %v", overflow, tt.overflow)
+			}
+		})
+	}
+}
+
+func TestParseRestDays(t *testing.T) {
+	tests := []struct {
+		value        string
+		weekdaysOnly bool
+		wantErr      bool
+		names        []string
+		rest         []string
+		study        []string
+	}{
+		{value: "", names: []string{}, study: []string{"2026-10-17", "2026-10-18", "2026-10-19"}},
+		{value: "", weekdaysOnly: true, names: []string{}, rest: []string{"2026-10-17", "2026-10-18"}, study: []string{"2026-10-16", "2026-10-19"}},
+		{value: "sun, Wednesday", names: []string{"sun", "wednesday"}, rest: []string{"2026-10-18", "2026-10-21"}, study: []string{"2026-10-17", "2026-10-20"}},
+		{value: "2026-12-25,mon", names: []string{"2026-12-25", "mon"}, rest: []string{"2026-12-25", "2026-10-19"}, study: []string{"2026-12-24", "2026-10-20"}},
+		{value: ",tue,,", names: []string{"tue"}, rest: []string{"2026-10-20"}},
+		{value: "wedx", wantErr: true},
+		{value: "someday", wantErr: true},
+		{value: "2026-13-01", wantErr: true},
+		{value: "mon,tue,wed,thu,fri", weekdaysOnly: true, wantErr: true},
+		{value: "mon,tue,wed,thu,fri,sat,sun", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		rest, names, err := ParseRestDays(tt.value, tt.weekdaysOnly)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRestDays(%q, %v) error = %v", tt.value, tt.weekdaysOnly, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("ParseRestDays(%q) names = %v, want %v", tt.value, names, tt.names)
+		}
+		for _, date := range tt.rest {
+			d, _ := time.Parse("2006-01-02", date)
+			if !rest.includes(d) {
+				t.Errorf("ParseRestDays(%q) studies on %s", tt.value, date)
+			}
+		}
+		for _, date := range tt.study {
+			d, _ := time.Parse("2006-01-02", date)
+			if rest.includes(d) {
+				t.Errorf("ParseRestDays(%q) rests on %s", tt.value, date)
+			}
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	// 2026-10-16 is a Friday.
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	plan := testPlan(3, 60, testMaterial("a", 60, false), testMaterial("b", 60, false), testMaterial("c", 60, false))
+
+	tests := []struct {
+		name         string
+		plan         Study_Plan
+		weekdaysOnly bool
+		restDays     string
+		dates        []string
+		end          string
+		feasible     bool
+	}{
+		{
+			name:     "every day",
+			plan:     plan,
+			dates:    []string{"2026-10-16", "2026-10-17", "2026-10-18"},
+			end:      "2026-10-18",
+			feasible: true,
+		},
+		{
+			name:         "weekdays only",
+			plan:         plan,
+			weekdaysOnly: true,
+			dates:        []string{"2026-10-16", "2026-10-19", "2026-10-20"},
+			end:          "2026-10-20",
+			feasible:     true,
+		},
+		{
+			name:         "weekdays only with a day off",
+			plan:         plan,
+			weekdaysOnly: true,
+			restDays:     "2026-10-19",
+			dates:        []string{"2026-10-16", "2026-10-20", "2026-10-21"},
+			end:          "2026-10-21",
+			feasible:     true,
+		},
+		{
+			name:     "starting on a rest day",
+			plan:     plan,
+			restDays: "fri",
+			dates:    []string{"2026-10-17", "2026-10-18", "2026-10-19"},
+			end:      "2026-10-19",
+			feasible: true,
+		},
+		{
+			name:  "more study days than the plan has",
+			plan:  testPlan(2, 60, testMaterial("a", 60, false), testMaterial("b", 60, false), testMaterial("c", 60, false)),
+			dates: []string{"2026-10-16", "2026-10-17", "2026-10-18"},
+			end:   "2026-10-18",
+		},
+		{
+			name:  "a day over budget",
+			plan:  testPlan(3, 30, testMaterial("v", 45, true)),
+			dates: []string{"2026-10-16"},
+			end:   "2026-10-16",
+		},
+		{
+			name:     "empty plan",
+			plan:     testPlan(3, 60),
+			dates:    []string{},
+			end:      "2026-10-16",
+			feasible: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, names, err := ParseRestDays(tt.restDays, tt.weekdaysOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schedule := tt.plan.Schedule(start, tt.weekdaysOnly, rest, names)
+
+			dates := []string{}
+			for _, day := range schedule.Days {
+				dates = append(dates, day.Date)
+				d, _ := time.Parse("2006-01-02", day.Date)
+				if day.Weekday != d.Weekday().String() {
+					t.Errorf("%s is labelled %s", day.Date, day.Weekday)
+				}
+			}
+			if !reflect.DeepEqual(dates, tt.dates) {
+				t.Errorf("dates = %v, want %v", dates, tt.dates)
+			}
+			if schedule.Start != "2026-10-16" || schedule.End != tt.end {
+				t.Errorf("schedule runs %s to %s, want 2026-10-16 to %s", schedule.Start, schedule.End, tt.end)
+			}
+			if schedule.Feasible != tt.feasible {
+				t.Errorf("feasible = %v, want %v", schedule.Feasible, tt.feasible)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login on one device. Each session has its own refresh token,
+// so refreshing or revoking it does not affect the user's other devices.
+type Session struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	User_id            string             `json:"user_id"`
+	User_agent         string             `json:"user_agent"`
+	Ip                 string             `json:"ip"`
+	Refresh_token_hash string             `json:"-"`
+	Access_jti         string             `json:"-"`
+	Access_expires_at  time.Time          `json:"-"`
+	Refresh_jti        string             `json:"-"`
+	Refresh_expires_at time.Time          `json:"-"`
+	Revoked_at         *time.Time         `json:"revoked_at,omitempty"`
+	Current            bool               `json:"current" bson:"-"`
+	Session_id         string             `json:"session_id"`
+	Created_at         time.Time          `json:"created_at"`
+	Last_seen_at       time.Time          `json:"last_seen_at"`
+}
@@ -7,16 +7,40 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_Name    *string            `json:"first_name" validate:"required,min=3"`
-	Last_Name     *string            `json:"last_name" validate:"required,min=2"`
-	Password      *string            `json:"password" validate:"required,min=8,max=16"`
-	Email         *string            `json:"email" validate:"required,email"`
-	Phone         *string            `json:"phone" validate:"required"`
-	Token         *string            `json:"token"`
-	User_type     *string            `json:"user_type" validate:"required,eq=USER|eq=ADMIN"`
-	Refresh_token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID         primitive.ObjectID `bson:"_id"`
+	First_Name *string            `json:"first_name" validate:"required,min=3"`
+	Last_Name  *string            `json:"last_name" validate:"required,min=2"`
+	Password   *string            `json:"password" validate:"required,min=8,max=16"`
+	Email      *string            `json:"email" validate:"required,email"`
+	Phone      *string            `json:"phone" validate:"required"`
+	User_type  *string            `json:"user_type" validate:"required,role"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	User_id    string             `json:"user_id"`
+
+	Totp_enabled   bool     `json:"totp_enabled"`
+	Totp_secret    *string  `json:"-"`
+	Totp_last_step int64    `json:"-"`
+	Recovery_codes []string `json:"-"`
+
+	Oidc_issuer  string `json:"-"`
+	Oidc_subject string `json:"-"`
+
+	Deleted_at     *time.Time `json:"deleted_at,omitempty"`
+	Deactivated_at *time.Time `json:"deactivated_at,omitempty"`
+
+	Role_updated_by string     `json:"role_updated_by,omitempty"`
+	Role_updated_at *time.Time `json:"role_updated_at,omitempty"`
+
+	// Mentor_id is the mentor following this student. Only admins assign
+	// it, so it is never read from request bodies.
+	Mentor_id *string `json:"-"`
+
+	Email_verified                bool       `json:"email_verified"`
+	Pending_email                 *string    `json:"pending_email,omitempty"`
+	Email_verification_hash       *string    `json:"-"`
+	Email_verification_expires_at *time.Time `json:"-"`
+
+	Password_reset_hash       *string    `json:"-"`
+	Password_reset_expires_at *time.Time `json:"-"`
 }
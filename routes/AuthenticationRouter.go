@@ -9,4 +9,13 @@ import (
 func AuthJWTroutes(routes *gin.Engine) {
 	routes.POST("users/signup", controller.SignUp())
 	routes.POST("users/login", controller.Login())
+	routes.POST("users/refresh", controller.RefreshToken())
+	routes.POST("users/password/forgot", controller.ForgotPassword())
+	routes.POST("users/password/reset", controller.ResetPassword())
+	routes.GET("users/verify", controller.VerifyEmail())
+	routes.POST("users/2fa/verify", controller.VerifyTwoFactor())
+	routes.GET(".well-known/jwks.json", controller.GetJWKS())
+	routes.GET("auth/oidc/login", controller.OIDCLogin())
+	routes.GET("auth/oidc/callback", controller.OIDCCallback())
+	routes.GET("calendar/:token", controller.GetCalendarFeed())
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	controller "Gate/controllers"
+	"Gate/helpers"
 	"Gate/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -9,15 +10,63 @@ import (
 
 func UserRoutes(routes *gin.Engine) {
 	routes.Use(middleware.Authenticate())
-	routes.GET("users", controller.GetUsers())
-	routes.GET("users/:user_id", controller.GetUser())
-	routes.POST("admin/study_material", controller.AddStudyMaterial())
-	routes.POST("admin/course", controller.AddCourse())
-	routes.POST("admin/study_plan", controller.AddStudyPlan())
-	routes.GET("admin/study_materials", controller.GetStudyMaterials())
-	routes.GET("admin/courses", controller.GetCourses())
-	routes.GET("admin/study_plans", controller.GetStudyPlans())
-	routes.GET("study_materials/:study_material", controller.GetStudyMaterial())
-	routes.GET("courses/:course", controller.GetCourse())
-	routes.GET("study_plans/:study_plan", controller.GetStudyPlan())
+	can := middleware.RequirePermission
+	session := middleware.RequireSession()
+
+	routes.GET("users", can(helpers.PermUserRead), controller.GetUsers())
+	routes.GET("users/:user_id", can(helpers.PermAccountSelf), controller.GetUser())
+	routes.POST("users/logout", can(helpers.PermAccountSelf), controller.Logout())
+	routes.POST("users/verify/resend", can(helpers.PermAccountSelf), controller.ResendVerificationEmail())
+	routes.POST("users/2fa/setup", can(helpers.PermAccountSelf), session, controller.SetupTwoFactor())
+	routes.POST("users/2fa/confirm", can(helpers.PermAccountSelf), session, controller.ConfirmTwoFactor())
+	routes.GET("users/me", can(helpers.PermAccountSelf), controller.GetMe())
+	routes.PATCH("users/me", can(helpers.PermAccountSelf), session, controller.UpdateMe())
+	routes.DELETE("users/me", can(helpers.PermAccountSelf), session, controller.DeleteMe())
+	routes.GET("users/me/export", can(helpers.PermAccountSelf), controller.ExportMe())
+	routes.GET("users/me/progress", can(helpers.PermAccountSelf), controller.GetMyProgress())
+	routes.GET("users/me/enrollments", can(helpers.PermAccountSelf), controller.GetMyEnrollments())
+	routes.POST("users/me/enrollments/:enrollment_id/calendar", can(helpers.PermAccountSelf), controller.CreateCalendarFeed())
+	routes.DELETE("users/me/enrollments/:enrollment_id/calendar", can(helpers.PermAccountSelf), controller.DeleteCalendarFeed())
+	routes.GET("users/:user_id/progress", can(helpers.PermProgressRead), controller.GetUserProgress())
+	routes.GET("users/me/students", can(helpers.PermProgressRead), controller.GetMyStudents())
+	routes.POST("users/me/password", can(helpers.PermAccountSelf), session, controller.ChangePassword())
+	routes.GET("users/me/sessions", can(helpers.PermAccountSelf), controller.GetSessions())
+	routes.DELETE("users/me/sessions/:session_id", can(helpers.PermAccountSelf), controller.DeleteSession())
+	routes.POST("users/me/api_keys", can(helpers.PermAccountSelf), session, controller.CreateAPIKey())
+	routes.GET("users/me/api_keys", can(helpers.PermAccountSelf), controller.GetAPIKeys())
+	routes.DELETE("users/me/api_keys/:api_key_id", can(helpers.PermAccountSelf), controller.RevokeAPIKey())
+	routes.GET("admin/users/export", can(helpers.PermUserRead), controller.ExportUsers())
+	routes.POST("admin/users/import", can(helpers.PermUserWrite), controller.ImportUsers())
+	routes.GET("admin/users/import/:import_job_id", can(helpers.PermUserWrite), controller.GetImportJob())
+	routes.POST("admin/users/bulk", can(helpers.PermUserWrite), controller.BulkUpdateUsers())
+	routes.POST("admin/users/:user_id/revoke-sessions", can(helpers.PermUserWrite), controller.RevokeUserSessions())
+	routes.DELETE("admin/users/:user_id", can(helpers.PermUserWrite), controller.DeleteUser())
+	routes.PUT("admin/users/:user_id/mentor", can(helpers.PermUserWrite), controller.AssignMentor())
+	routes.POST("admin/users/:user_id/unlock", can(helpers.PermUserWrite), controller.UnlockUser())
+	routes.PATCH("admin/users/:user_id/role", can(helpers.PermUserWrite), controller.UpdateUserRole())
+	routes.GET("admin/roles", can(helpers.PermUserWrite), controller.GetRoles())
+	routes.PUT("admin/roles/:role", can(helpers.PermRoleWrite), controller.PutRole())
+	routes.POST("admin/study_material", can(helpers.PermMaterialWrite), controller.AddStudyMaterial())
+	routes.POST("admin/course", can(helpers.PermCourseWrite), controller.AddCourse())
+	routes.POST("admin/study_plan", can(helpers.PermPlanWrite), controller.AddStudyPlan())
+	routes.PUT("admin/study_material/:study_material", can(helpers.PermMaterialWrite), controller.UpdateStudyMaterial())
+	routes.PATCH("admin/study_material/:study_material", can(helpers.PermMaterialWrite), controller.UpdateStudyMaterial())
+	routes.DELETE("admin/study_material/:study_material", can(helpers.PermMaterialWrite), controller.DeleteStudyMaterial())
+	routes.PUT("admin/course/:course", can(helpers.PermCourseWrite), controller.UpdateCourse())
+	routes.PATCH("admin/course/:course", can(helpers.PermCourseWrite), controller.UpdateCourse())
+	routes.DELETE("admin/course/:course", can(helpers.PermCourseWrite), controller.DeleteCourse())
+	routes.PUT("admin/study_plan/:study_plan", can(helpers.PermPlanWrite), controller.UpdateStudyPlan())
+	routes.PATCH("admin/study_plan/:study_plan", can(helpers.PermPlanWrite), controller.UpdateStudyPlan())
+	routes.POST("admin/durations/recompute", can(helpers.PermCourseWrite), can(helpers.PermPlanWrite), controller.RecomputeDurations())
+	routes.DELETE("admin/study_plan/:study_plan", can(helpers.PermPlanWrite), controller.DeleteStudyPlan())
+	routes.GET("admin/study_materials", can(helpers.PermMaterialWrite), controller.GetStudyMaterials())
+	routes.GET("admin/courses", can(helpers.PermCourseWrite), controller.GetCourses())
+	routes.GET("admin/study_plans", can(helpers.PermPlanWrite), controller.GetStudyPlans())
+	routes.GET("study_materials/:study_material", can(helpers.PermMaterialRead), controller.GetStudyMaterial())
+	routes.GET("courses/:course", can(helpers.PermCourseRead), controller.GetCourse())
+	routes.GET("study_plans/:study_plan", can(helpers.PermPlanRead), controller.GetStudyPlan())
+	routes.GET("study_plans/:study_plan/feasibility", can(helpers.PermPlanRead), controller.GetPlanFeasibility())
+	routes.GET("study_plans/:study_plan/schedule", can(helpers.PermPlanRead), controller.GetStudySchedule())
+	routes.POST("study_plans/:study_plan/enroll", can(helpers.PermPlanRead), controller.EnrollInPlan())
+	routes.POST("study_materials/:study_material/complete", can(helpers.PermMaterialRead), controller.CompleteMaterial())
 }